@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"orbit/models"
+	"orbit/pkg/zone_files"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare two zone snapshots and report added, removed and modified records per origin.",
+	Long: "Compare two zone snapshots and report added, removed and modified records per origin. Each snapshot\n" +
+		"may be any zone source accepted by --iZ, including directories and archives.",
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func init() {
+	DiffCmd.Flags().String("format", "text", "Output format, either text or json.")
+	RootCmd.AddCommand(DiffCmd)
+}
+
+func runDiff(c *cobra.Command, args []string) error {
+	format, _ := c.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unsupported output format %s", format)
+	}
+	include, _ := c.Flags().GetString("include")
+	exclude, _ := c.Flags().GetString("exclude")
+	lenient, _ := c.Flags().GetBool("lenient")
+
+	var snapshots [2][]models.ZoneFile
+	for i, path := range args {
+		dz := zone_files.DNSZones{Include: SplitList(include), Exclude: SplitList(exclude), Lenient: lenient}
+		zones, err := dz.GetZoneData(path)
+		if err != nil {
+			return err
+		}
+		snapshots[i] = zones
+	}
+	dz := zone_files.DNSZones{}
+	diffs := dz.DiffZones(snapshots[0], snapshots[1])
+
+	if format == "json" {
+		enc := json.NewEncoder(c.OutOrStdout())
+		enc.SetIndent("", "  ")
+		if diffs == nil {
+			diffs = []models.ZoneDiff{}
+		}
+		return enc.Encode(diffs)
+	}
+	printZoneDiffs(c.OutOrStdout(), diffs)
+	return nil
+}
+
+func printZoneDiffs(w io.Writer, diffs []models.ZoneDiff) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "---- %s ----\n", diff.Origin)
+		for _, rec := range diff.Removed {
+			fmt.Fprintln(w, "-", formatRecord(rec))
+		}
+		for _, rec := range diff.Added {
+			fmt.Fprintln(w, "+", formatRecord(rec))
+		}
+		for _, change := range diff.Modified {
+			fmt.Fprintln(w, "~", formatRecord(change.Old), "->", fmt.Sprintf("%d %s", change.New.TTL, change.New.Content))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func formatRecord(rec models.DNSRecord) string {
+	return fmt.Sprintf("%s %d %s %s %s", rec.Name, rec.TTL, rec.Class, rec.Type, rec.Content)
+}
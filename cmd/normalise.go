@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"orbit/pkg/zone_files"
+)
+
+var NormaliseCmd = &cobra.Command{
+	Use:   "normalise <source>",
+	Short: "Rewrite zones from any supported source as canonical, sorted RFC 1035 zone files.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNormalise,
+}
+
+func init() {
+	NormaliseCmd.Flags().String("out", "", "Directory to write <origin>.zone files to. Zones are written to stdout when empty.")
+	RootCmd.AddCommand(NormaliseCmd)
+}
+
+func runNormalise(c *cobra.Command, args []string) error {
+	out, _ := c.Flags().GetString("out")
+	include, _ := c.Flags().GetString("include")
+	exclude, _ := c.Flags().GetString("exclude")
+	lenient, _ := c.Flags().GetBool("lenient")
+
+	dz := zone_files.DNSZones{Include: SplitList(include), Exclude: SplitList(exclude), Lenient: lenient}
+	zones, err := dz.GetZoneData(args[0])
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		for i, zone := range zones {
+			if i > 0 {
+				fmt.Fprintln(c.OutOrStdout())
+			}
+			if err := dz.WriteZoneFile(c.OutOrStdout(), zone); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	paths, err := dz.WriteZoneFiles(out, zones)
+	for _, path := range paths {
+		fmt.Fprintln(c.OutOrStdout(), path)
+	}
+	return err
+}
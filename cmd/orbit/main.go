@@ -11,32 +11,78 @@ import (
 	"orbit/pkg/ip_addresses"
 	"orbit/pkg/reporting"
 	"orbit/pkg/zone_files"
+	"orbit/pkg/zone_lint"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
+// streamBatchSize bounds the number of records held at once when streaming zones.
+const streamBatchSize = 10000
+
 var (
 	zones  = zone_files.DNSZones{}
 	ipa    = ip_addresses.IPAddresses{}
 	rep    = reporting.Reporting{}
 	dna    = dns_analysers.DNSAnalyser{}
+	engine = dns_analysers.LookupEngine{}
+	lint   = zone_lint.ZoneLinter{}
 	assess = models.ASMAssessment{}
 )
 
 func main() {
-	err := cmd.Execute()
+	ran, err := cmd.Execute()
 	if err != nil {
 		log.Println("[!] Failed to parse initialise arguments: ", err.Error())
 		log.Print("[!] Exiting...")
 		os.Exit(1)
 	}
+	// Subcommands such as diff do their own work.
+	if ran != cmd.RootCmd {
+		return
+	}
+
+	include, _ := cmd.RootCmd.PersistentFlags().GetString("include")
+	exclude, _ := cmd.RootCmd.PersistentFlags().GetString("exclude")
+	zones.Include = cmd.SplitList(include)
+	zones.Exclude = cmd.SplitList(exclude)
+	zones.Lenient, _ = cmd.RootCmd.PersistentFlags().GetBool("lenient")
+
+	resolvers, _ := cmd.RootCmd.PersistentFlags().GetString("resolvers")
+	zoneResolvers, _ := cmd.RootCmd.PersistentFlags().GetString("zone-resolvers")
+	var opts dns_analysers.ResolverOptions
+	opts.QPS, _ = cmd.RootCmd.PersistentFlags().GetFloat64("qps")
+	opts.Retries, _ = cmd.RootCmd.PersistentFlags().GetInt("retries")
+	opts.Backoff, _ = cmd.RootCmd.PersistentFlags().GetDuration("backoff")
+	opts.DoHMethod, _ = cmd.RootCmd.PersistentFlags().GetString("doh-method")
+	dna.Resolver, err = dns_analysers.NewResolver(cmd.SplitList(resolvers), cmd.SplitList(zoneResolvers), opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	dna.Nameservers = dns_analysers.NewNameserverResolver(opts)
+	whoisQPS, _ := cmd.RootCmd.PersistentFlags().GetFloat64("whois-qps")
+	dna.WhoisLimiter = &dns_analysers.RateLimiter{QPS: whoisQPS}
+	engine.Workers, _ = cmd.RootCmd.PersistentFlags().GetInt("workers")
+	engine.Jitter, _ = cmd.RootCmd.PersistentFlags().GetDuration("jitter")
+	openCache()
 
 	zf, _ := cmd.RootCmd.PersistentFlags().GetString("iZ")
-	if zf != "" {
+	if stream, _ := cmd.RootCmd.PersistentFlags().GetBool("stream"); zf != "" && stream {
+		checkStreamFlags()
+		streamZoneData(zf)
+	} else if zf != "" {
 		getZoneData(zf)
 	}
 
+	xfr, _ := cmd.RootCmd.PersistentFlags().GetString("iX")
+	if xfr != "" {
+		tsig, _ := cmd.RootCmd.PersistentFlags().GetString("tsig")
+		transferZones(xfr, tsig)
+	}
+
 	ips, _ := cmd.RootCmd.PersistentFlags().GetString("iI")
 	if ips != "" {
 		readIPsFile(ips)
@@ -47,28 +93,138 @@ func main() {
 		readUrlsFile(urlList)
 	}
 
-	// Get IP addresses from A/AAA records
-	for i := range assess.Zones {
-		aRecs := rep.AandAAARecords(&assess.Zones[i])
-		ipa.AddManyIPStrAddresses(aRecs, &assess.IPAddresses)
-	}
+	analyseZones(0)
 
-	// Get aliases
-	getAliasesFromZones()
+	// Attempt zone transfers from the nameservers of every known zone and domain
+	if axfr, _ := cmd.RootCmd.PersistentFlags().GetBool("check-axfr"); axfr {
+		checkZoneTransfers()
+	}
 
 	// Lookup IPs of all known domains and track otherwise unknown IPs
 	domainIPLookups()
 
+	// Follow the CNAME chain of every known domain to the name which serves it
+	resolveCNAMEChains()
+
 	processReverseLookups()
 
+	// Compare the answers of the authoritative nameservers, the resolver and the zone files
+	if compare, _ := cmd.RootCmd.PersistentFlags().GetBool("compare-answers"); compare {
+		compareAnswers()
+	}
+
 	// Review all IP addresses and note any exposed internal IP addresses in records.
 	reviewPrivateIPs()
 
+	zoneOut, _ := cmd.RootCmd.PersistentFlags().GetString("oZ")
+	if zoneOut != "" {
+		writeZoneFiles(zoneOut)
+	}
+
 	// Print test data
 	printURLTargets()
 	printUntrackedIPs()
 	printDNSSECMissing()
 	printHostingProviders()
+	printReverseNames()
+	printCNAMEChains()
+	printZoneTransfers()
+	printAnswerMismatches()
+	printFailedLookups()
+	printZoneFindings()
+	printDroppedZoneData()
+
+	if err := dna.Cache.Save(); err != nil {
+		log.Printf("Error saving cache: %v\n", err)
+	}
+}
+
+// openCache loads the DNS and WHOIS cache, purging it first when asked to. Lookups are not cached when
+// the cache is bypassed.
+func openCache() {
+	path, _ := cmd.RootCmd.PersistentFlags().GetString("cache")
+	if path == "" {
+		var err error
+		path, err = dns_analysers.DefaultCachePath()
+		if err != nil {
+			log.Printf("Caching disabled: %v\n", err)
+			return
+		}
+	}
+	if purge, _ := cmd.RootCmd.PersistentFlags().GetBool("purge-cache"); purge {
+		if err := dns_analysers.PurgeCache(path); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if bypass, _ := cmd.RootCmd.PersistentFlags().GetBool("no-cache"); bypass {
+		return
+	}
+	maxAge, _ := cmd.RootCmd.PersistentFlags().GetDuration("whois-max-age")
+	cache, err := dns_analysers.OpenCache(path, maxAge)
+	if err != nil {
+		fmt.Printf("Error reading cache %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	dna.Cache = cache
+}
+
+// checkStreamFlags rejects the options which need every record of the zones, as streamed zones only
+// keep their origins.
+func checkStreamFlags() {
+	for _, flag := range []string{"oZ", "compare-answers", "check-axfr"} {
+		if cmd.RootCmd.PersistentFlags().Changed(flag) {
+			fmt.Printf("--%s cannot be used with --stream, as streamed zones do not keep their records\n", flag)
+			os.Exit(1)
+		}
+	}
+	log.Println("[!] Streamed zones are not linted or DNSSEC validated")
+}
+
+// streamZoneData reads zones record by record and extracts their addresses, hostnames and PTR mappings
+// from bounded batches of records. Only the origins of the zones are kept in the assessment.
+func streamZoneData(zf string) {
+	batch := models.ZoneFile{}
+	origins := make(map[string]bool)
+	// The sets of known addresses and domains are kept across batches rather than rebuilt for each one.
+	knownIPs := ipa.AddressSet(&assess.IPAddresses)
+	knownDomains := rep.DomainSet(&assess)
+	flush := func() {
+		if len(batch.Records) == 0 {
+			return
+		}
+		var ips []net.IP
+		for _, addr := range rep.AandAAARecords(&batch) {
+			if ip := net.ParseIP(addr); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		ipa.AddNewIPAddresses(ips, knownIPs, &assess.IPAddresses)
+		ips, targets := reverseZoneData(&batch)
+		ipa.AddNewIPAddresses(ips, knownIPs, &assess.IPAddresses)
+		rep.AddNewURLsToAsmDomains(targets, knownDomains, &assess)
+		if !ipa.IsReverseZone(batch.Origin) {
+			rep.AddNewURLsToAsmDomains(rep.GetFQDNs(&batch), knownDomains, &assess)
+		}
+		batch.Records = batch.Records[:0]
+	}
+	err := zones.StreamZoneData(zf, func(zr zone_files.ZoneRecord) error {
+		if zr.Origin != batch.Origin || len(batch.Records) == streamBatchSize {
+			flush()
+			batch.Origin = zr.Origin
+		}
+		if !origins[zr.Origin] {
+			origins[zr.Origin] = true
+			assess.Zones = append(assess.Zones, models.ZoneFile{Origin: zr.Origin})
+		}
+		batch.Records = append(batch.Records, zr.Record)
+		return nil
+	})
+	flush()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
 
 func getZoneData(zf string) {
@@ -77,7 +233,37 @@ func getZoneData(zf string) {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	assess.Zones = zfResults
+	assess.Zones = append(assess.Zones, zfResults...)
+}
+
+func transferZones(targets, tsig string) {
+	var key *zone_files.TSIGKey
+	if tsig != "" {
+		var err error
+		if key, err = zone_files.ParseTSIGKey(tsig); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	transfers, err := zone_files.ParseZoneTransfers(targets, key)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, xfr := range transfers {
+		zf, err := zones.TransferZone(xfr)
+		if err != nil {
+			log.Println("[!]", err)
+			continue
+		}
+		assess.Zones = append(assess.Zones, zf)
+	}
+}
+
+func writeZoneFiles(dir string) {
+	if _, err := zones.WriteZoneFiles(dir, assess.Zones); err != nil {
+		log.Println("[!]", err)
+	}
 }
 
 func readIPsFile(ips string) {
@@ -110,8 +296,40 @@ func readUrlsFile(urlList string) {
 	}
 }
 
-func getAliasesFromZones() {
-	for _, zone := range assess.Zones {
+// analyseZones lints the zones of the assessment from an index on and adds their addresses, reverse
+// names and hostnames to it.
+func analyseZones(from int) {
+	// Check zones for misconfigurations, records which disclose information and DNSSEC problems
+	for i := from; i < len(assess.Zones); i++ {
+		assess.ZoneFindings = append(assess.ZoneFindings, lint.LintZone(&assess.Zones[i])...)
+		assess.ZoneFindings = append(assess.ZoneFindings, lint.ValidateDNSSEC(&assess.Zones[i], time.Now())...)
+	}
+
+	// Get IP addresses from A/AAA records
+	for i := from; i < len(assess.Zones); i++ {
+		aRecs := rep.AandAAARecords(&assess.Zones[i])
+		ipa.AddManyIPStrAddresses(aRecs, &assess.IPAddresses)
+	}
+
+	// Map the PTR records of reverse zones back to IP addresses and hostnames
+	getReverseZoneData(from)
+
+	// Get aliases
+	getAliasesFromZones(from)
+}
+
+func getAliasesFromZones(from int) {
+	for _, zone := range assess.Zones[from:] {
+		// Check for DNSSEC enablement
+		if res, _ := dna.DNSSECEnabled(zone.Origin); res {
+			rep.AddMissingDNSSec(zone.Origin, &assess)
+		}
+
+		// Reverse zones name IP addresses rather than hosts, see getReverseZoneData.
+		if ipa.IsReverseZone(zone.Origin) {
+			continue
+		}
+
 		aliases := rep.CNAMERecords(&zone)
 		// Remove .gtm domains. These are mostly subdomains used elsewhere
 		temp := make([]map[string]string, len(aliases))
@@ -138,38 +356,207 @@ func getAliasesFromZones() {
 			assess.Aliases = append(assess.Aliases, al)
 		}
 
-		// Check for DNSSEC enablement
-		if res, _ := dna.DNSSECEnabled(zone.Origin); res {
-			rep.AddMissingDNSSec(zone.Origin, &assess)
-		}
-
 		ip := net.ParseIP(zone.Origin)
 		if ip != nil && !ipa.IPExistsIn(ip, &assess.IPAddresses) {
 			ipa.CheckAddIPtoAddresses(ip, &assess.IPAddresses)
 		} else {
-			fqdns := rep.GetFQDNs(&zone)
-			for i := range fqdns {
-				rep.AddURLToAsmDomainsDupSafe(fqdns[i], &assess)
+			rep.AddManyURLsToAsmDomains(rep.GetFQDNs(&zone), &assess)
+		}
+	}
+}
+
+// getReverseZoneData adds the IP addresses named by PTR records to the assessment and the hostnames
+// they point to as domains.
+func getReverseZoneData(from int) {
+	for i := from; i < len(assess.Zones); i++ {
+		ips, targets := reverseZoneData(&assess.Zones[i])
+		ipa.AddManyIPAddresses(ips, &assess.IPAddresses)
+		rep.AddManyURLsToAsmDomains(targets, &assess)
+	}
+}
+
+// reverseZoneData records the hostnames the PTR records of a zone map IP addresses to, and returns the
+// addresses and hostnames.
+func reverseZoneData(zone *models.ZoneFile) ([]net.IP, []string) {
+	var ips []net.IP
+	var targets []string
+	for _, rec := range zone.Records {
+		if rec.Type != "PTR" || rec.Target == "" {
+			continue
+		}
+		ip := ipa.ReverseNameToIP(rec.Name)
+		if ip == nil {
+			continue
+		}
+		ips = append(ips, ip)
+		targets = append(targets, rec.Target)
+		rep.AddReverseName(ip.String(), rec.Target, &assess)
+	}
+	return ips, targets
+}
+
+func checkZoneTransfers() {
+	var targets []string
+	for _, zone := range assess.Zones {
+		if !rep.SliceContainsString(targets, zone.Origin) {
+			targets = append(targets, zone.Origin)
+		}
+	}
+	apexes := dns_analysers.RunLookups(&engine, assess.Domains, func(domain string) string {
+		apex, _ := dna.ZoneApex(domain)
+		return apex
+	})
+	for _, apex := range apexes {
+		if apex != "" && !rep.SliceContainsString(targets, apex) {
+			targets = append(targets, apex)
+		}
+	}
+
+	type transfer struct {
+		results []models.ZoneTransferResult
+		leaked  []models.ZoneFile
+		err     error
+	}
+	transfers := dns_analysers.RunLookups(&engine, targets, func(zone string) transfer {
+		results, leaked, err := dna.CheckZoneTransfer(zone)
+		return transfer{results: results, leaked: leaked, err: err}
+	})
+	known := len(assess.Zones)
+	for i, xfr := range transfers {
+		if xfr.err != nil {
+			assess.ZoneTransfers = append(assess.ZoneTransfers, models.ZoneTransferResult{Zone: targets[i], Error: xfr.err.Error()})
+			continue
+		}
+		assess.ZoneTransfers = append(assess.ZoneTransfers, xfr.results...)
+		for _, zf := range xfr.leaked {
+			rep.MergeZoneRecords(zf, &assess)
+		}
+	}
+	// Zones which were only found by transferring them are analysed like the supplied ones.
+	analyseZones(known)
+}
+
+// compareAnswers compares the answers of the authoritative nameservers with the resolver and the zone
+// files for the record sets of every name in the zones, and the addresses of every other known domain.
+func compareAnswers() {
+	type nameRecords struct {
+		zone    string
+		records []models.DNSRecord
+	}
+	names := make(map[string]*nameRecords)
+	var order []string
+	for _, zone := range assess.Zones {
+		// Streamed zones only keep their origins.
+		for _, rec := range zone.Records {
+			key := strings.ToLower(rec.Name)
+			if names[key] == nil {
+				names[key] = &nameRecords{zone: zone.Origin}
+				order = append(order, key)
 			}
+			names[key].records = append(names[key].records, rec)
+		}
+	}
+	var domains []string
+	for _, domain := range assess.Domains {
+		if names[strings.ToLower(domain)] == nil {
+			domains = append(domains, strings.ToLower(domain))
+		}
+	}
+	apexes := dns_analysers.RunLookups(&engine, domains, func(domain string) string {
+		apex, _ := dna.ZoneApex(domain)
+		return apex
+	})
+	for i, domain := range domains {
+		if apexes[i] != "" && names[domain] == nil {
+			names[domain] = &nameRecords{zone: apexes[i]}
+			order = append(order, domain)
+		}
+	}
+
+	var zoneList []string
+	for _, name := range order {
+		if !rep.SliceContainsString(zoneList, names[name].zone) {
+			zoneList = append(zoneList, names[name].zone)
+		}
+	}
+	lookups := dns_analysers.RunLookups(&engine, zoneList, func(zone string) []string {
+		servers, _ := dna.AuthoritativeServers(zone)
+		return servers
+	})
+	servers := make(map[string][]string)
+	for i, zone := range zoneList {
+		servers[zone] = lookups[i]
+	}
+
+	checks := make(map[string]dns_analysers.AnswerCheck)
+	var targets []string
+	for _, name := range order {
+		nr := names[name]
+		if len(servers[nr.zone]) == 0 {
+			continue
+		}
+		for _, check := range dna.AnswerChecks(name, nr.records, len(nr.records) > 0) {
+			check.Nameservers = servers[nr.zone]
+			key := fmt.Sprintf("%s %d", name, check.Type)
+			checks[key] = check
+			targets = append(targets, key)
+		}
+	}
+	results := dns_analysers.RunLookups(&engine, targets, func(key string) []models.AnswerMismatch {
+		return dna.CompareAnswers(checks[key])
+	})
+	for _, mismatches := range results {
+		assess.AnswerMismatches = append(assess.AnswerMismatches, mismatches...)
+	}
+}
+
+// resolveCNAMEChains records the full CNAME chain of every known domain which is an alias, including
+// aliases pointing outside the zones.
+func resolveCNAMEChains() {
+	chains := dns_analysers.RunLookups(&engine, assess.Domains, func(domain string) models.CNAMEChain {
+		chain, _ := dna.ResolveCNAMEChain(domain)
+		return chain
+	})
+	for _, chain := range chains {
+		if len(chain.Targets) > 0 {
+			rep.SetCNAMEChain(chain, &assess)
 		}
 	}
 }
 
 func processReverseLookups() {
+	type reverseLookup struct {
+		domains  []string
+		err      error
+		whois    string
+		whoisErr error
+	}
 	hosters := make(map[string][]string)
 	allowed := []string{"money", "fx", "ttt", "novo", "explore", "currency"}
-	for _, ip := range assess.IPAddresses.IPv4 {
-		domains, err := dna.ReverseLookup(ip.String())
+	targets := make([]string, len(assess.IPAddresses.IPv4))
+	for i, ip := range assess.IPAddresses.IPv4 {
+		targets[i] = ip.String()
+	}
+	lookups := dns_analysers.RunLookups(&engine, targets, func(ip string) reverseLookup {
+		domains, err := dna.ReverseLookup(ip)
 		if err != nil {
+			return reverseLookup{err: err}
+		}
+		whois, err := dna.Whois(ip)
+		return reverseLookup{domains: domains, whois: whois, whoisErr: err}
+	})
+
+	for i, ip := range assess.IPAddresses.IPv4 {
+		if lookups[i].err != nil {
 			continue
 		}
+		domains := lookups[i].domains
 
 		var host string
-		whois, err := dna.Whois(ip.String())
-		if err != nil {
+		if lookups[i].whoisErr != nil {
 			host = "Unknown hosting provider."
 		} else {
-			w := dna.ParseWHOIS(whois)
+			w := dna.ParseWHOIS(lookups[i].whois)
 			e := w.OrgAbuseEmail
 			if strings.Contains(e, "@") {
 				host = strings.Split(e, "@")[1]
@@ -232,12 +619,21 @@ func processReverseLookups() {
 }
 
 func domainIPLookups() {
+	type ipLookup struct {
+		ips []net.IP
+		err error
+	}
+	lookups := dns_analysers.RunLookups(&engine, assess.Domains, func(domain string) ipLookup {
+		ips, err := dna.IPLookup(domain)
+		return ipLookup{ips: ips, err: err}
+	})
 	for i := range assess.Domains {
 		var ut models.UntrackedIP
 		ut.Domain = assess.Domains[i]
-		ips, err := dna.IPLookup(assess.Domains[i])
+		ips, err := lookups[i].ips, lookups[i].err
+		rep.SetLookupStatus(assess.Domains[i], dns_analysers.LookupStatus(err), &assess)
 		if err != nil {
-			break
+			continue
 		}
 		for ip := range ips {
 			if !ipa.IPExistsIn(ips[ip], &assess.IPAddresses) && ips[ip] != nil {
@@ -299,25 +695,25 @@ func printURLTargets() {
 
 func printUntrackedIPs() {
 	fmt.Println("\n---- Untracked IPs ----")
-	// More mem efficient with a loop instead of append?
-	ips := make([]net.IP, 0)
-	for _, ip := range assess.UntrackedIPAddresses {
-		ips = append(ips, ip.Addresses.IPv4...)
-	}
+	var ips, domains []string
 	for _, utIps := range assess.UntrackedIPAddresses {
 		var comb []net.IP
 		comb = append(comb, utIps.Addresses.IPv4...)
 		comb = append(comb, utIps.Addresses.IPv6...)
 		for _, ip := range comb {
-			who, err := dna.Whois(ip.String())
-			if err != nil || strings.Contains(who, "Error: Invalid query") {
-				who = "Unknown WHOIS"
-			} else {
-				parsedWhois := dna.ParseWHOIS(who)
-				who = parsedWhois.OrgName
-			}
-			fmt.Printf("%s - %s - %s\n", ip.String(), utIps.Domain, who)
+			ips = append(ips, ip.String())
+			domains = append(domains, utIps.Domain)
+		}
+	}
+	owners := dns_analysers.RunLookups(&engine, ips, func(ip string) string {
+		who, err := dna.Whois(ip)
+		if err != nil || strings.Contains(who, "Error: Invalid query") {
+			return "Unknown WHOIS"
 		}
+		return dna.ParseWHOIS(who).OrgName
+	})
+	for i := range ips {
+		fmt.Printf("%s - %s - %s\n", ips[i], domains[i], owners[i])
 	}
 }
 
@@ -334,3 +730,79 @@ func printHostingProviders() {
 		fmt.Println(key)
 	}
 }
+
+func printReverseNames() {
+	fmt.Println("\n---- Reverse DNS Names ----")
+	ips := make([]string, 0, len(assess.ReverseNames))
+	for ip := range assess.ReverseNames {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		fmt.Printf("%s - %s\n", ip, strings.Join(assess.ReverseNames[ip], ", "))
+	}
+}
+
+func printCNAMEChains() {
+	fmt.Println("\n---- CNAME Chains ----")
+	hosts := make([]string, 0, len(assess.CNAMEChains))
+	for host := range assess.CNAMEChains {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		chain := assess.CNAMEChains[host]
+		fmt.Printf("%s -> %s - %s (%s)\n", host, strings.Join(chain.Targets, " -> "), strings.Join(chain.Addresses, ", "), chain.Status)
+	}
+}
+
+func printZoneTransfers() {
+	fmt.Println("\n---- Zone Transfers Allowed ----")
+	for _, xfr := range assess.ZoneTransfers {
+		if xfr.Allowed {
+			fmt.Printf("%s - %s (%s) - %d records\n", xfr.Zone, xfr.Nameserver, xfr.Address, xfr.Records)
+		}
+	}
+
+	// Zones whose nameservers could not be found were not checked at all.
+	fmt.Println("\n---- Zone Transfers Not Checked ----")
+	for _, xfr := range assess.ZoneTransfers {
+		if xfr.Nameserver == "" && xfr.Error != "" {
+			fmt.Printf("%s - %s\n", xfr.Zone, xfr.Error)
+		}
+	}
+}
+
+func printAnswerMismatches() {
+	fmt.Println("\n---- Answer Mismatches ----")
+	for _, m := range assess.AnswerMismatches {
+		fmt.Printf("[%s] %s %s - %s\n", m.Kind, m.Name, m.Type, m.Detail)
+	}
+}
+
+func printFailedLookups() {
+	fmt.Println("\n---- Failed Lookups ----")
+	for _, domain := range assess.Domains {
+		if status, ok := assess.LookupStatus[domain]; ok && status != dns_analysers.StatusOK {
+			fmt.Printf("%s - %s\n", domain, status)
+		}
+	}
+}
+
+func printZoneFindings() {
+	fmt.Println("\n---- Zone Findings ----")
+	for _, f := range assess.ZoneFindings {
+		fmt.Printf("[%s] %s - %s %s - %s\n", f.Severity, f.Check, f.Name, f.Type, f.Detail)
+	}
+}
+
+func printDroppedZoneData() {
+	fmt.Println("\n---- Dropped Zone Files ----")
+	for _, dropped := range zones.DroppedFiles {
+		fmt.Println(dropped.Error())
+	}
+	fmt.Println("\n---- Dropped Zone Lines ----")
+	for _, dropped := range zones.DroppedLines {
+		fmt.Println(dropped.Error())
+	}
+}
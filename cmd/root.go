@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"strings"
+	"time"
 )
 
 var (
@@ -11,12 +13,35 @@ var (
 	}
 )
 
-func Execute() error {
-	return RootCmd.Execute()
+// Execute runs the command line and returns the command which was run.
+func Execute() (*cobra.Command, error) {
+	return RootCmd.ExecuteC()
 }
 
 func init() {
-	RootCmd.PersistentFlags().String("iZ", "", "Input is .zone file or directory.")
+	RootCmd.PersistentFlags().String("iZ", "", "Input is .zone or BIND db file, Route 53, Azure DNS or Google Cloud DNS export (.json), octoDNS config (.yaml), tinydns data file, .tar.gz/.zip archive or directory.")
+	RootCmd.PersistentFlags().String("include", "", "Comma separated globs selecting the files read from zone directories and archives.")
+	RootCmd.PersistentFlags().String("exclude", "", "Comma separated globs of files and directories to skip in zone directories and archives.")
+	RootCmd.PersistentFlags().Bool("lenient", false, "Skip zone file lines and provider export record sets which fail to parse rather than the whole file.")
+	RootCmd.PersistentFlags().Bool("stream", false, "Stream --iZ zones record by record for very large zones. Only addresses, hostnames and origins are kept, so zones are not linted or DNSSEC validated, and --oZ, --compare-answers and --check-axfr cannot be used.")
+	RootCmd.PersistentFlags().String("iX", "", "Zones to transfer, as a comma separated list of zone@server[:port][#serial]. A serial requests an IXFR of the changes since it instead of an AXFR.")
+	RootCmd.PersistentFlags().String("tsig", "", "TSIG key for zone transfers as [algorithm:]name:secret.")
+	RootCmd.PersistentFlags().String("oZ", "", "Directory to write every loaded and discovered zone to as canonical zone files.")
+	RootCmd.PersistentFlags().String("resolvers", "", "Comma separated upstream resolvers as host[:port], tls://host[:port][#name] for DNS-over-TLS, an https:// URL for DNS-over-HTTPS, or 'system' for the operating system's resolvers. Defaults to 8.8.8.8.")
+	RootCmd.PersistentFlags().String("zone-resolvers", "", "Comma separated resolvers for specific zones as zone@resolver, i.e., corp.example@10.0.0.53.")
+	RootCmd.PersistentFlags().String("doh-method", "POST", "HTTP method of DNS-over-HTTPS queries, GET or POST.")
+	RootCmd.PersistentFlags().Int("retries", 2, "Number of times a DNS query is retried after a timeout or SERVFAIL.")
+	RootCmd.PersistentFlags().Duration("backoff", 250*time.Millisecond, "Delay before the first retry of a DNS query, doubled for each retry after it.")
+	RootCmd.PersistentFlags().Int("workers", 10, "Number of DNS and WHOIS lookups run at once.")
+	RootCmd.PersistentFlags().Float64("qps", 0, "Maximum queries per second sent to each resolver. Zero means no limit.")
+	RootCmd.PersistentFlags().Float64("whois-qps", 1, "Maximum WHOIS queries per second. Zero means no limit.")
+	RootCmd.PersistentFlags().Duration("jitter", 0, "Upper bound of a random delay before each lookup, i.e., 250ms.")
+	RootCmd.PersistentFlags().Bool("check-axfr", false, "Attempt unauthenticated zone transfers (AXFR) of every known zone and the zone of every known domain from each of their nameservers.")
+	RootCmd.PersistentFlags().Bool("compare-answers", false, "Query the authoritative nameservers of every name directly and report answers which differ from the resolver or the zone files.")
+	RootCmd.PersistentFlags().String("cache", "", "File DNS and WHOIS lookups are cached in between runs. Defaults to orbit/cache.json in the user's cache directory.")
+	RootCmd.PersistentFlags().Bool("no-cache", false, "Bypass the cache, sending every lookup and caching none of them.")
+	RootCmd.PersistentFlags().Bool("purge-cache", false, "Remove every cached lookup before running.")
+	RootCmd.PersistentFlags().Duration("whois-max-age", 7*24*time.Hour, "How long WHOIS records are cached for. DNS responses are cached for their TTL.")
 	RootCmd.PersistentFlags().String("iI", "", "Input file containing IP addresses.")
 	RootCmd.PersistentFlags().String("iU", "", "Input file containing URLs.")
 	//RootCmd.PersistentFlags().BoolP("a-records", "a", false, "Print A and AAA records.")
@@ -24,3 +49,14 @@ func init() {
 	//RootCmd.PersistentFlags().BoolP("targets", "t", false, "Created an FQDN target list. Uses CNAME and A/AAA.")
 	//RootCmd.PersistentFlags().BoolP("ips", "i", false, "Created target list. Uses IPv4 and IPv6 values.")
 }
+
+// SplitList splits a comma separated flag value, dropping empty items.
+func SplitList(s string) []string {
+	var results []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			results = append(results, item)
+		}
+	}
+	return results
+}
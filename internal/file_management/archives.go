@@ -0,0 +1,148 @@
+package file_management
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxArchiveBytes limits the total size of the files extracted from an archive, including those of
+// the archives nested within it.
+const maxArchiveBytes = 256 << 20
+
+// ExtractionBudget holds the number of bytes which may still be extracted. Archives nested in another
+// archive share its budget, so nesting cannot multiply the limit.
+type ExtractionBudget struct {
+	remaining int64
+}
+
+// NewExtractionBudget returns the budget of an archive read from outside any other archive.
+func NewExtractionBudget() *ExtractionBudget {
+	return &ExtractionBudget{remaining: maxArchiveBytes}
+}
+
+var archiveExtensions = []string{".tar.gz", ".tgz", ".zip"}
+
+// IsArchive returns true for the .tar.gz, .tgz and .zip bundles zone files can be read from.
+func IsArchive(path string) bool {
+	name := strings.ToLower(path)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractArchive extracts the regular files of a .tar.gz, .tgz or .zip archive into a directory.
+// Entries which would be written outside the directory are rejected, as is extracting more than the
+// budget allows.
+func ExtractArchive(path, dest string, budget *ExtractionBudget) error {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return extractZip(path, dest, budget)
+	}
+	return extractTarGz(path, dest, budget)
+}
+
+func extractZip(path, dest string, budget *ExtractionBudget) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer func(reader *zip.ReadCloser) {
+		err := reader.Close()
+		if err != nil {
+			log.Printf("error closing archive: %s", path)
+		}
+	}(reader)
+
+	for _, f := range reader.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		written, err := extractFile(rc, dest, f.Name, budget.remaining)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+		budget.remaining -= written
+	}
+	return nil
+}
+
+func extractTarGz(path, dest string, budget *ExtractionBudget) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", file.Name())
+		}
+	}(file)
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		written, err := extractFile(tr, dest, hdr.Name, budget.remaining)
+		if err != nil {
+			return err
+		}
+		budget.remaining -= written
+	}
+}
+
+// extractFile writes an archive entry below dest, copying no more than limit bytes.
+func extractFile(r io.Reader, dest, name string, limit int64) (int64, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dest, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return 0, fmt.Errorf("archive entry %s is outside the extraction directory", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer func(out *os.File) {
+		err := out.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", out.Name())
+		}
+	}(out)
+
+	written, err := io.Copy(out, io.LimitReader(r, limit+1))
+	if err != nil {
+		return written, fmt.Errorf("%s: %w", name, err)
+	}
+	if written > limit {
+		return written, fmt.Errorf("archive exceeds the %d byte extraction limit", maxArchiveBytes)
+	}
+	return written, nil
+}
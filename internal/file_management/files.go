@@ -7,6 +7,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 func ReadFileBytes(path string) ([]byte, error) {
@@ -50,9 +52,101 @@ func ReadFileLines(path string) ([]string, error) {
 	return res, nil
 }
 
+// maxLineBytes bounds the length of a single line read by ScanFileLines.
+const maxLineBytes = 1 << 20
+
+// ScanFileLines calls fn with each line of a file and its line number as the file is read, rather
+// than loading the whole file into memory. It stops at the first error fn returns.
+func ScanFileLines(path string, fn func(line string, num int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.New("unable to open file")
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", file.Name())
+		}
+	}(file)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	for num := 1; scanner.Scan(); num++ {
+		if err := fn(scanner.Text(), num); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+var zoneFileExtensions = []string{".zone", ".db", ".json", ".yaml", ".yml", ".tinydns"}
+
+// IsZoneFile returns true for files which may contain zone data, including tinydns 'data' files and
+// BIND's 'db.example.com' naming convention.
 func IsZoneFile(file os.DirEntry) bool {
-	if !file.IsDir() && filepath.Ext(file.Name()) == ".zone" {
-		return true
+	if file.IsDir() {
+		return false
+	}
+	name := file.Name()
+	return name == "data" || strings.HasPrefix(name, "db.") || slices.Contains(zoneFileExtensions, filepath.Ext(name))
+}
+
+// FirstLine returns the first line of a file which is not blank and does not start with one of the
+// comment prefixes.
+func FirstLine(path string, commentPrefixes ...string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", file.Name())
+		}
+	}(file)
+
+	scanner := bufio.NewScanner(file)
+lines:
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		for _, prefix := range commentPrefixes {
+			if strings.HasPrefix(line, prefix) {
+				continue lines
+			}
+		}
+		return line
+	}
+	return ""
+}
+
+// IsJSONFile returns true if the first non-whitespace character of a file opens a JSON object or array.
+func IsJSONFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", file.Name())
+		}
+	}(file)
+
+	reader := bufio.NewReader(file)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', '[':
+			return true
+		}
+		return false
 	}
-	return false
 }
@@ -8,11 +8,75 @@ type ZoneFile struct {
 }
 
 type DNSRecord struct {
-	Type    string
-	Class   string
-	Name    string
-	Content string
-	TTL     int
+	Type    string `json:"type"`
+	Class   string `json:"class"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	// Typed record data. Only the fields relevant to Type are set and names have no trailing dot.
+	Target string    `json:"target,omitempty"` // CNAME, DNAME, NS and PTR
+	TXT    []string  `json:"txt,omitempty"`
+	MX     *MXData   `json:"mx,omitempty"`
+	SRV    *SRVData  `json:"srv,omitempty"`
+	SOA    *SOAData  `json:"soa,omitempty"`
+	CAA    *CAAData  `json:"caa,omitempty"`
+	SVCB   *SVCBData `json:"svcb,omitempty"` // SVCB and HTTPS
+	DS     *DSData   `json:"ds,omitempty"`
+	// Attributes holds provider specific details of imported records such as routing policies and alias targets.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+type MXData struct {
+	Preference int    `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+type SRVData struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+type SOAData struct {
+	MName   string `json:"mname"`
+	RName   string `json:"rname"`
+	Serial  uint32 `json:"serial"`
+	Refresh int    `json:"refresh"`
+	Retry   int    `json:"retry"`
+	Expire  int    `json:"expire"`
+	Minimum int    `json:"minimum"`
+}
+
+type CAAData struct {
+	Flag  int    `json:"flag"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+type SVCBData struct {
+	Priority int               `json:"priority"`
+	Target   string            `json:"target"`
+	Params   map[string]string `json:"params"`
+}
+
+type DSData struct {
+	KeyTag     int    `json:"keyTag"`
+	Algorithm  int    `json:"algorithm"`
+	DigestType int    `json:"digestType"`
+	Digest     string `json:"digest"`
+}
+
+type ZoneDiff struct {
+	Origin   string         `json:"origin"`
+	Added    []DNSRecord    `json:"added,omitempty"`
+	Removed  []DNSRecord    `json:"removed,omitempty"`
+	Modified []RecordChange `json:"modified,omitempty"`
+}
+
+type RecordChange struct {
+	Old DNSRecord `json:"old"`
+	New DNSRecord `json:"new"`
 }
 
 type IPCollection struct {
@@ -30,6 +94,17 @@ type ASMAssessment struct {
 	MissingDNSSEC        []string
 	Aliases              []AliasRecords
 	HostingProviders     map[string][]string
+	ZoneTransfers        []ZoneTransferResult
+	ZoneFindings         []ZoneFinding
+	// ReverseNames maps IP addresses to the hostnames named by PTR records in reverse zones.
+	ReverseNames map[string][]string
+	// LookupStatus holds the outcome of the address lookup of each domain, i.e., ok or nxdomain.
+	LookupStatus map[string]string
+	// AnswerMismatches are record sets answered differently by the authoritative nameservers, the
+	// recursive resolver and the zone files.
+	AnswerMismatches []AnswerMismatch
+	// CNAMEChains maps hostnames which are aliases to the chain of CNAMEs they resolve through.
+	CNAMEChains map[string]CNAMEChain
 }
 
 type UntrackedIP struct {
@@ -37,6 +112,42 @@ type UntrackedIP struct {
 	Addresses IPCollection
 }
 
+type ZoneFinding struct {
+	Zone     string
+	Name     string
+	Type     string
+	Check    string
+	Severity string
+	Detail   string
+}
+
+type ZoneTransferResult struct {
+	Zone       string
+	Nameserver string
+	Address    string
+	Allowed    bool
+	Records    int
+	Error      string
+}
+
+type AnswerMismatch struct {
+	Name   string
+	Type   string
+	Kind   string
+	Detail string
+}
+
+// CNAMEChain is the chain of aliases a hostname resolves through to the name which serves it.
+type CNAMEChain struct {
+	Hostname string
+	// Targets are the target of each CNAME in the chain, in order.
+	Targets []string
+	// Addresses are the addresses of the last name in the chain.
+	Addresses []string
+	// Status is the outcome of resolving the chain, i.e., ok, nxdomain or cname-loop.
+	Status string
+}
+
 type AliasRecords struct {
 	Domain       string
 	Relationship []map[string]string
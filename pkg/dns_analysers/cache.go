@@ -0,0 +1,206 @@
+package dns_analysers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps DNS responses and WHOIS records on disk between runs. DNS responses expire with the
+// lowest TTL they contain and WHOIS records after WhoisMaxAge. A nil Cache caches nothing.
+type Cache struct {
+	Path        string
+	WhoisMaxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	Data    []byte    `json:"data"`
+	Stored  time.Time `json:"stored"`
+	Expires time.Time `json:"expires"`
+}
+
+// OpenCache loads the cache stored at a path. A cache which does not exist yet starts out empty.
+func OpenCache(path string, whoisMaxAge time.Duration) (*Cache, error) {
+	c := &Cache{Path: path, WhoisMaxAge: whoisMaxAge, entries: make(map[string]cacheEntry), now: time.Now}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// PurgeCache removes the cache stored at a path.
+func PurgeCache(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// DefaultCachePath returns the cache file in the user's cache directory.
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "orbit", "cache.json"), nil
+}
+
+// Save writes the entries which have not expired to disk, replacing the previous cache file.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.Expires) {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(c.entries)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), ".cache-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Path)
+}
+
+func (c *Cache) get(key string) (cacheEntry, bool) {
+	if c == nil {
+		return cacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.Expires) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *Cache) put(key string, data []byte, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.entries[key] = cacheEntry{Data: data, Stored: now, Expires: now.Add(ttl)}
+}
+
+// message returns the cached response of a resolver to a query with its TTLs reduced by the time it
+// has been cached.
+func (c *Cache) message(resolver Resolver, query *dns.Msg) (*dns.Msg, bool) {
+	entry, ok := c.get(dnsCacheKey(resolver, query))
+	if !ok {
+		return nil, false
+	}
+	msg := new(dns.Msg)
+	if err := msg.Unpack(entry.Data); err != nil {
+		return nil, false
+	}
+	age := uint32(c.now().Sub(entry.Stored) / time.Second)
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			if hdr := rr.Header(); hdr.Rrtype != dns.TypeOPT {
+				hdr.Ttl -= min(age, hdr.Ttl)
+			}
+		}
+	}
+	msg.Id = query.Id
+	return msg, true
+}
+
+// storeMessage caches a positive or negative response for as long as its TTLs allow. Failures and
+// truncated responses are not cached.
+func (c *Cache) storeMessage(resolver Resolver, query, msg *dns.Msg) {
+	if c == nil || msg.Truncated || (msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError) {
+		return
+	}
+	data, err := msg.Pack()
+	if err != nil {
+		return
+	}
+	c.put(dnsCacheKey(resolver, query), data, time.Duration(responseTTL(msg))*time.Second)
+}
+
+func (c *Cache) whois(target string) (string, bool) {
+	entry, ok := c.get("whois " + strings.ToLower(target))
+	return string(entry.Data), ok
+}
+
+func (c *Cache) storeWhois(target, result string) {
+	if c != nil {
+		c.put("whois "+strings.ToLower(target), []byte(result), c.WhoisMaxAge)
+	}
+}
+
+// dnsCacheKey identifies a query by the servers it is sent to as well as the question, so answers from
+// an organisation's internal resolvers and public ones are cached apart.
+func dnsCacheKey(resolver Resolver, query *dns.Msg) string {
+	q := query.Question[0]
+	return "dns " + resolverIdentity(resolver, query) + " " + strings.ToLower(q.Name) + " " + dns.ClassToString[q.Qclass] + " " + dns.TypeToString[q.Qtype]
+}
+
+// resolverIdentity returns the servers a resolver sends a query to.
+func resolverIdentity(resolver Resolver, query *dns.Msg) string {
+	switch r := resolver.(type) {
+	case *UpstreamResolver:
+		return strings.Join(r.Servers, ",")
+	case *ZoneResolver:
+		return resolverIdentity(r.resolverFor(query), query)
+	}
+	return fmt.Sprintf("%T", resolver)
+}
+
+// responseTTL returns the lowest TTL of the answer and authority records. The TTL of an SOA record is
+// capped by its minimum field, which gives the negative caching TTL of the zone (RFC 2308).
+func responseTTL(msg *dns.Msg) uint32 {
+	var ttl uint32
+	found := false
+	for _, rr := range append(append([]dns.RR(nil), msg.Answer...), msg.Ns...) {
+		t := rr.Header().Ttl
+		if soa, ok := rr.(*dns.SOA); ok {
+			t = min(t, soa.Minttl)
+		}
+		if !found || t < ttl {
+			ttl, found = t, true
+		}
+	}
+	return ttl
+}
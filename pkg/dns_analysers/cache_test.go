@@ -0,0 +1,159 @@
+package dns_analysers
+
+import (
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countQueries starts a local server which answers from a list of records, or with NXDOMAIN and the
+// SOA of example.com, and counts the queries it receives.
+func countQueries(t *testing.T, queries *int32, records ...string) string {
+	var rrs []dns.RR
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		assert.NoError(t, err)
+		rrs = append(rrs, rr)
+	}
+	soa, err := dns.NewRR("example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 900 1209600 300")
+	assert.NoError(t, err)
+	return serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(queries, 1)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		for _, rr := range rrs {
+			if dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(q.Name) && rr.Header().Rrtype == q.Qtype {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		if len(m.Answer) == 0 {
+			m.Rcode = dns.RcodeNameError
+			m.Ns = []dns.RR{soa}
+		}
+		_ = w.WriteMsg(m)
+	})
+}
+
+func TestCache(t *testing.T) {
+	var queries int32
+	addr := countQueries(t, &queries, "www.example.com. 60 IN TXT \"v=spf1 -all\"")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	newAnalyser := func(path string) DNSAnalyser {
+		cache, err := OpenCache(path, time.Hour)
+		assert.NoError(t, err)
+		cache.now = clock
+		return DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{addr}, Timeout: time.Second}, Cache: cache}
+	}
+
+	t.Run("Responses are reused until their TTL expires.", func(t *testing.T) {
+		atomic.StoreInt32(&queries, 0)
+		an := newAnalyser(filepath.Join(t.TempDir(), "cache.json"))
+
+		_, err := an.GetTXT("www.example.com")
+		assert.NoError(t, err)
+		now = now.Add(59 * time.Second)
+		msg, err := an.initDNSMsg("www.example.com", dns.TypeTXT)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&queries))
+		assert.Equal(t, uint32(1), msg.Answer[0].Header().Ttl)
+
+		now = now.Add(time.Second)
+		_, err = an.GetTXT("www.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&queries))
+	})
+
+	t.Run("Negative responses are cached for the SOA minimum.", func(t *testing.T) {
+		atomic.StoreInt32(&queries, 0)
+		an := newAnalyser(filepath.Join(t.TempDir(), "cache.json"))
+
+		_, err := an.GetTXT("missing.example.com")
+		assert.Equal(t, StatusNXDOMAIN, LookupStatus(err))
+		now = now.Add(299 * time.Second)
+		_, err = an.GetTXT("missing.example.com")
+		assert.Equal(t, StatusNXDOMAIN, LookupStatus(err))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&queries))
+
+		now = now.Add(time.Second)
+		_, _ = an.GetTXT("missing.example.com")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&queries))
+	})
+
+	t.Run("Saved entries are used by the next run.", func(t *testing.T) {
+		atomic.StoreInt32(&queries, 0)
+		path := filepath.Join(t.TempDir(), "orbit", "cache.json")
+		an := newAnalyser(path)
+		_, err := an.GetTXT("www.example.com")
+		assert.NoError(t, err)
+		an.Cache.storeWhois("192.0.2.10", "OrgName: Example")
+		assert.NoError(t, an.Cache.Save())
+
+		an = newAnalyser(path)
+		_, err = an.GetTXT("www.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&queries))
+		result, err := an.Whois("192.0.2.10")
+		assert.NoError(t, err)
+		assert.Equal(t, "OrgName: Example", result)
+
+		now = now.Add(time.Hour)
+		_, ok := an.Cache.whois("192.0.2.10")
+		assert.False(t, ok)
+	})
+
+	t.Run("A purged cache starts out empty.", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+		an := newAnalyser(path)
+		an.Cache.storeWhois("192.0.2.10", "OrgName: Example")
+		assert.NoError(t, an.Cache.Save())
+
+		assert.NoError(t, PurgeCache(path))
+		assert.NoError(t, PurgeCache(path))
+		an = newAnalyser(path)
+		_, ok := an.Cache.whois("192.0.2.10")
+		assert.False(t, ok)
+	})
+
+	t.Run("Answers are cached per resolver.", func(t *testing.T) {
+		var internalQueries int32
+		internal := countQueries(t, &internalQueries, "www.example.com. 60 IN TXT \"internal\"")
+		atomic.StoreInt32(&queries, 0)
+		an := newAnalyser(filepath.Join(t.TempDir(), "cache.json"))
+		txt, err := an.GetTXT("www.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"v=spf1 -all"}, txt)
+
+		an.Resolver = &ZoneResolver{Default: an.Resolver, Zones: map[string]Resolver{
+			"example.com": &UpstreamResolver{Servers: []string{internal}, Timeout: time.Second},
+		}}
+		txt, err = an.GetTXT("www.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"internal"}, txt)
+		_, _ = an.GetTXT("www.example.com")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&queries))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&internalQueries))
+	})
+
+	t.Run("Failures are not cached.", func(t *testing.T) {
+		failing := serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+			atomic.AddInt32(&queries, 1)
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			_ = w.WriteMsg(m)
+		})
+		atomic.StoreInt32(&queries, 0)
+		an := newAnalyser(filepath.Join(t.TempDir(), "cache.json"))
+		an.Resolver = &UpstreamResolver{Servers: []string{failing}, Timeout: time.Second}
+
+		_, err := an.GetTXT("www.example.com")
+		assert.Equal(t, StatusSERVFAIL, LookupStatus(err))
+		_, _ = an.GetTXT("www.example.com")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&queries))
+	})
+}
@@ -0,0 +1,251 @@
+package dns_analysers
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"orbit/models"
+	"orbit/pkg/zone_files"
+	"sort"
+	"strings"
+)
+
+// Kinds of differences found between the answers for a record set.
+const (
+	// MismatchNameservers means the authoritative nameservers of a zone gave different answers.
+	MismatchNameservers = "nameserver-disagreement"
+	// MismatchResolver means the recursive resolver answered differently from the authoritative
+	// nameservers, i.e., a split-horizon view of the zone or a cached copy which has since changed. The
+	// remaining TTL of a cached answer does not tell the two apart, so they are reported alike.
+	MismatchResolver = "resolver-differs"
+	// MismatchUnpublished means records in the zone file are not served by the nameservers.
+	MismatchUnpublished = "unpublished"
+	// MismatchNotExported means records served by the nameservers are missing from the zone file.
+	MismatchNotExported = "not-exported"
+)
+
+// AnswerCheck describes a record set to compare across the zone's authoritative nameservers, the
+// recursive resolver and the zone file it was supplied in.
+type AnswerCheck struct {
+	Name string
+	Type uint16
+	// Nameservers are the addresses of the zone's authoritative nameservers.
+	Nameservers []string
+	// InZoneFile is set when a zone file containing the name was supplied, in which case ZoneRecords
+	// holds its records of the name and type, if any.
+	InZoneFile  bool
+	ZoneRecords []models.DNSRecord
+}
+
+// authoritativeAnswer is the record set returned by one authoritative nameserver.
+type authoritativeAnswer struct {
+	server string
+	rrs    []dns.RR
+}
+
+// CompareAnswers queries the authoritative nameservers of a record set directly and compares their
+// answers with each other, the recursive resolver's answer and the zone file. Nameservers which fail
+// or do not answer authoritatively are left out, and nothing is compared when none of them answer.
+func (an *DNSAnalyser) CompareAnswers(check AnswerCheck) []models.AnswerMismatch {
+	name := strings.TrimSuffix(check.Name, ".")
+	qtype := dns.TypeToString[check.Type]
+
+	var answers []authoritativeAnswer
+	for _, server := range check.Nameservers {
+		if answer, ok := an.authoritativeQuery(server, name, check.Type); ok {
+			answers = append(answers, answer)
+		}
+	}
+	if len(answers) == 0 {
+		return nil
+	}
+
+	var mismatches []models.AnswerMismatch
+	mismatch := func(kind, detail string) {
+		mismatches = append(mismatches, models.AnswerMismatch{Name: name, Type: qtype, Kind: kind, Detail: detail})
+	}
+
+	published := answers[0].rrs
+	for _, answer := range answers[1:] {
+		if !sameRRs(answers[0].rrs, answer.rrs) {
+			mismatch(MismatchNameservers, fmt.Sprintf("%s answers %s but %s answers %s",
+				answers[0].server, rdataList(answers[0].rrs), answer.server, rdataList(answer.rrs)))
+		}
+		for _, rr := range answer.rrs {
+			if !containsRR(published, rr) {
+				published = append(published, rr)
+			}
+		}
+	}
+
+	// The recursive resolver is queried without the cache so its own answer is compared.
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), check.Type)
+	m.RecursionDesired = true
+	msg, err := an.resolver().Exchange(m)
+	status := LookupStatus(classify(name, check.Type, msg, err))
+	if status == StatusOK || status == StatusNXDOMAIN {
+		recursive := answerRRs(msg, name, check.Type)
+		if !sameRRs(published, recursive) {
+			mismatch(MismatchResolver, fmt.Sprintf("resolver answers %s but nameservers answer %s", rdataList(recursive), rdataList(published)))
+		}
+	}
+
+	if check.InZoneFile {
+		var exported []dns.RR
+		for _, rec := range check.ZoneRecords {
+			if rr, err := zone_files.RecordToRR(rec); err == nil {
+				exported = append(exported, rr)
+			}
+		}
+		if missing := missingRRs(exported, published); len(missing) > 0 {
+			mismatch(MismatchUnpublished, "zone file has "+rdataList(missing)+" which the nameservers do not serve")
+		}
+		if missing := missingRRs(published, exported); len(missing) > 0 {
+			mismatch(MismatchNotExported, "nameservers serve "+rdataList(missing)+" which the zone file does not have")
+		}
+	}
+	return mismatches
+}
+
+// AuthoritativeServers returns the addresses of every nameserver of a zone.
+func (an *DNSAnalyser) AuthoritativeServers(zone string) ([]string, error) {
+	nameservers, err := an.GetNS(zone)
+	if err != nil {
+		return nil, err
+	}
+	var servers []string
+	for _, ns := range nameservers {
+		addrs, err := an.IPLookup(ns)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			servers = append(servers, addr.String())
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no addresses found for the nameservers of %s", zone)
+	}
+	return servers, nil
+}
+
+// AnswerChecks returns the record sets of a name to compare: every type in the zone files apart from
+// DNSSEC records, and its addresses unless it is an alias. Sets with provider specific attributes such
+// as routing policies are not compared with the zone files, as their answers depend on the provider.
+func (an *DNSAnalyser) AnswerChecks(name string, records []models.DNSRecord, inZoneFile bool) []AnswerCheck {
+	var types []uint16
+	sets := make(map[uint16][]models.DNSRecord)
+	comparable := make(map[uint16]bool)
+	// Provider aliases are answered with records of the aliased type, or with addresses when it is not
+	// known, so those types are compared with the nameservers only.
+	var flattened []uint16
+	for _, rec := range records {
+		if rec.Type != "ALIAS" {
+			continue
+		}
+		if t, ok := dns.StringToType[rec.Attributes["alias-type"]]; ok {
+			flattened = append(flattened, t)
+		} else {
+			flattened = append(flattened, dns.TypeA, dns.TypeAAAA)
+		}
+	}
+	for _, rec := range records {
+		t, ok := dns.StringToType[rec.Type]
+		if !ok || t == dns.TypeRRSIG || t == dns.TypeNSEC || t == dns.TypeNSEC3 || t == dns.TypeNSEC3PARAM {
+			continue
+		}
+		if _, seen := sets[t]; !seen {
+			types = append(types, t)
+			comparable[t] = inZoneFile
+		}
+		sets[t] = append(sets[t], rec)
+		if len(rec.Attributes) > 0 {
+			comparable[t] = false
+		}
+	}
+	if _, alias := sets[dns.TypeCNAME]; !alias {
+		for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			if _, seen := sets[t]; !seen {
+				types = append(types, t)
+				comparable[t] = inZoneFile
+			}
+		}
+	}
+
+	for _, t := range flattened {
+		if _, seen := comparable[t]; !seen {
+			types = append(types, t)
+		}
+		comparable[t] = false
+	}
+
+	checks := make([]AnswerCheck, len(types))
+	for i, t := range types {
+		checks[i] = AnswerCheck{Name: name, Type: t, InZoneFile: comparable[t], ZoneRecords: sets[t]}
+	}
+	return checks
+}
+
+// authoritativeQuery sends a non-recursive query to a nameserver and returns its answer when the
+// server is authoritative for the name. Referrals and failures are not answers.
+func (an *DNSAnalyser) authoritativeQuery(server, name string, qtype uint16) (authoritativeAnswer, bool) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = false
+	msg, err := an.nameservers().ExchangeWith(m, server)
+	if err != nil || !msg.Authoritative || (msg.Rcode != dns.RcodeSuccess && msg.Rcode != dns.RcodeNameError) {
+		return authoritativeAnswer{}, false
+	}
+	return authoritativeAnswer{server: server, rrs: answerRRs(msg, name, qtype)}, true
+}
+
+// answerRRs returns the records of a response owned by the name and of the type asked for, leaving
+// out the CNAME chain and signatures which may come with them.
+func answerRRs(msg *dns.Msg, name string, qtype uint16) []dns.RR {
+	var rrs []dns.RR
+	for _, rr := range msg.Answer {
+		if rr.Header().Rrtype == qtype && dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(name) {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+// containsRR reports whether a set holds a record with the same name, class, type and RDATA.
+// TTLs and the case of names are ignored.
+func containsRR(set []dns.RR, rr dns.RR) bool {
+	for _, r := range set {
+		if dns.IsDuplicate(r, rr) {
+			return true
+		}
+	}
+	return false
+}
+
+// missingRRs returns the records of one set which are not in another.
+func missingRRs(from, in []dns.RR) []dns.RR {
+	var missing []dns.RR
+	for _, rr := range from {
+		if !containsRR(in, rr) && !containsRR(missing, rr) {
+			missing = append(missing, rr)
+		}
+	}
+	return missing
+}
+
+func sameRRs(a, b []dns.RR) bool {
+	return len(missingRRs(a, b)) == 0 && len(missingRRs(b, a)) == 0
+}
+
+// rdataList returns the sorted RDATA of a set of records, or 'no records' when it is empty.
+func rdataList(rrs []dns.RR) string {
+	if len(rrs) == 0 {
+		return "no records"
+	}
+	values := make([]string, len(rrs))
+	for i, rr := range rrs {
+		values[i] = strings.TrimPrefix(rr.String(), rr.Header().String())
+	}
+	sort.Strings(values)
+	return "[" + strings.Join(values, ", ") + "]"
+}
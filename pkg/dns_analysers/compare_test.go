@@ -0,0 +1,167 @@
+package dns_analysers
+
+import (
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"orbit/models"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serveZone starts a local nameserver which answers from a list of records, authoritatively when aa
+// is set.
+func serveZone(t *testing.T, aa bool, records ...string) string {
+	return serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := recordAnswer(t, r, records...)
+		m.Authoritative = aa
+		_ = w.WriteMsg(m)
+	})
+}
+
+func TestCompareAnswers(t *testing.T) {
+	zoneRecords := []models.DNSRecord{
+		{Name: "www.example.com", Type: "A", Class: "IN", TTL: 300, Content: "192.0.2.10"},
+		{Name: "www.example.com", Type: "A", Class: "IN", TTL: 300, Content: "192.0.2.11"},
+	}
+	published := []string{"www.example.com. 300 IN A 192.0.2.10", "www.example.com. 300 IN A 192.0.2.11"}
+	kinds := func(mismatches []models.AnswerMismatch) []string {
+		var kinds []string
+		for _, m := range mismatches {
+			assert.Equal(t, "www.example.com", m.Name)
+			assert.Equal(t, "A", m.Type)
+			kinds = append(kinds, m.Kind)
+		}
+		return kinds
+	}
+	check := func(nameservers ...string) AnswerCheck {
+		return AnswerCheck{Name: "www.example.com", Type: dns.TypeA, Nameservers: nameservers, InZoneFile: true, ZoneRecords: zoneRecords}
+	}
+	analyser := func(records ...string) DNSAnalyser {
+		return DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{serveRecords(t, records...)}, Timeout: time.Second}}
+	}
+
+	t.Run("Matching answers have no mismatches.", func(t *testing.T) {
+		an := analyser("www.example.com. 120 IN A 192.0.2.11", "www.example.com. 120 IN A 192.0.2.10")
+		assert.Empty(t, an.CompareAnswers(check(serveZone(t, true, published...), serveZone(t, true, published...))))
+	})
+
+	t.Run("Zone file records which are not published, and published records missing from it, are reported.", func(t *testing.T) {
+		an := analyser("www.example.com. 300 IN A 192.0.2.10", "www.example.com. 300 IN A 192.0.2.12")
+		ns := serveZone(t, true, "www.example.com. 300 IN A 192.0.2.10", "www.example.com. 300 IN A 192.0.2.12")
+		mismatches := an.CompareAnswers(check(ns))
+		assert.Equal(t, []string{MismatchUnpublished, MismatchNotExported}, kinds(mismatches))
+		assert.Contains(t, mismatches[0].Detail, "192.0.2.11")
+		assert.Contains(t, mismatches[1].Detail, "192.0.2.12")
+	})
+
+	t.Run("Nameservers which disagree are reported.", func(t *testing.T) {
+		an := analyser(published...)
+		ns1 := serveZone(t, true, published...)
+		ns2 := serveZone(t, true, published[0])
+		mismatches := an.CompareAnswers(check(ns1, ns2))
+		assert.Equal(t, []string{MismatchNameservers}, kinds(mismatches))
+		assert.Contains(t, mismatches[0].Detail, ns2)
+	})
+
+	t.Run("Resolver answers which differ are reported whatever their TTL.", func(t *testing.T) {
+		ns := serveZone(t, true, published...)
+		an := analyser("www.example.com. 120 IN A 192.0.2.10")
+		assert.Equal(t, []string{MismatchResolver}, kinds(an.CompareAnswers(check(ns))))
+
+		an = analyser("www.example.com. 300 IN A 10.0.0.10")
+		mismatches := an.CompareAnswers(check(ns))
+		assert.Equal(t, []string{MismatchResolver}, kinds(mismatches))
+		assert.Contains(t, mismatches[0].Detail, "10.0.0.10")
+
+		an = analyser()
+		mismatches = an.CompareAnswers(check(ns))
+		assert.Equal(t, []string{MismatchResolver}, kinds(mismatches))
+		assert.Contains(t, mismatches[0].Detail, "resolver answers no records")
+	})
+
+	t.Run("Names without zone file data are only compared with the resolver.", func(t *testing.T) {
+		an := analyser(published...)
+		c := check(serveZone(t, true, published[0]))
+		c.InZoneFile, c.ZoneRecords = false, nil
+		assert.Equal(t, []string{MismatchResolver}, kinds(an.CompareAnswers(c)))
+	})
+
+	t.Run("Nameservers are queried with the configured retries.", func(t *testing.T) {
+		var queries int32
+		ns := serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+			m := recordAnswer(t, r, published...)
+			m.Authoritative = true
+			if atomic.AddInt32(&queries, 1) == 1 {
+				m.Answer = nil
+				m.Rcode = dns.RcodeServerFailure
+			}
+			_ = w.WriteMsg(m)
+		})
+		an := analyser(published...)
+		an.Nameservers = NewNameserverResolver(ResolverOptions{Timeout: time.Second, Retries: 1})
+		assert.Empty(t, an.CompareAnswers(check(ns)))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&queries))
+	})
+
+	t.Run("Nothing is compared without an authoritative answer.", func(t *testing.T) {
+		an := analyser()
+		assert.Empty(t, an.CompareAnswers(check(serveZone(t, false), closedAddress(t))))
+	})
+}
+
+func TestAnswerChecks(t *testing.T) {
+	an := DNSAnalyser{}
+	records := []models.DNSRecord{
+		{Name: "www.example.com", Type: "CNAME", Target: "web.example.net"},
+		{Name: "example.com", Type: "MX"},
+		{Name: "example.com", Type: "RRSIG"},
+		{Name: "example.com", Type: "A", Attributes: map[string]string{"routing": "weighted"}},
+		{Name: "example.com", Type: "A"},
+	}
+
+	t.Run("Aliases are compared as CNAMEs only.", func(t *testing.T) {
+		checks := an.AnswerChecks("www.example.com", records[:1], true)
+		assert.Len(t, checks, 1)
+		assert.Equal(t, dns.TypeCNAME, checks[0].Type)
+		assert.True(t, checks[0].InZoneFile)
+	})
+
+	t.Run("Addresses are always compared and DNSSEC records never are.", func(t *testing.T) {
+		checks := an.AnswerChecks("example.com", records[1:], true)
+		var types []uint16
+		for _, c := range checks {
+			types = append(types, c.Type)
+		}
+		assert.Equal(t, []uint16{dns.TypeMX, dns.TypeA, dns.TypeAAAA}, types)
+		assert.True(t, checks[0].InZoneFile)
+		assert.True(t, checks[2].InZoneFile)
+	})
+
+	t.Run("Record sets with provider attributes are not compared with the zone file.", func(t *testing.T) {
+		checks := an.AnswerChecks("example.com", records[1:], true)
+		assert.False(t, checks[1].InZoneFile)
+		assert.Len(t, checks[1].ZoneRecords, 2)
+	})
+
+	t.Run("Provider aliases are compared as the type they alias with the nameservers only.", func(t *testing.T) {
+		alias := []models.DNSRecord{{Name: "example.com", Type: "ALIAS", Target: "lb.example.net", Attributes: map[string]string{"alias-type": "AAAA"}}}
+		checks := an.AnswerChecks("example.com", alias, true)
+		assert.Len(t, checks, 2)
+		assert.Equal(t, dns.TypeA, checks[0].Type)
+		assert.True(t, checks[0].InZoneFile)
+		assert.Equal(t, dns.TypeAAAA, checks[1].Type)
+		assert.False(t, checks[1].InZoneFile)
+
+		alias[0].Attributes = nil
+		for _, c := range an.AnswerChecks("example.com", alias, true) {
+			assert.False(t, c.InZoneFile)
+		}
+	})
+
+	t.Run("Names outside the zone files are compared by address.", func(t *testing.T) {
+		checks := an.AnswerChecks("app.example.com", nil, false)
+		assert.Len(t, checks, 2)
+		assert.False(t, checks[0].InZoneFile)
+	})
+}
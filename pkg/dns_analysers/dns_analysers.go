@@ -9,40 +9,57 @@ import (
 	"log"
 	"net"
 	"orbit/models"
+	"orbit/pkg/zone_files"
 	"regexp"
 	"strings"
+	"time"
 )
 
-type DNSAnalyser struct{}
-
-var resolverIP = "8.8.8.8"
+type DNSAnalyser struct {
+	// Resolver answers every query made by the analyser. Queries are sent to 8.8.8.8 when it is nil.
+	Resolver Resolver
+	// Cache answers repeated DNS and WHOIS lookups without sending them again. Nothing is cached when
+	// it is nil.
+	Cache *Cache
+	// WhoisLimiter limits the rate of WHOIS queries, which registries throttle far sooner than DNS.
+	// WHOIS queries are not limited when it is nil.
+	WhoisLimiter *RateLimiter
+	// Nameservers sends the queries made to authoritative nameservers directly, with its timeout,
+	// retries and rate limit. A resolver with the defaults is used when it is nil.
+	Nameservers *UpstreamResolver
+}
 
+// Whois returns the WHOIS record of a domain or IP address, from the cache when it has not expired.
 func (an *DNSAnalyser) Whois(target string) (string, error) {
 	if target == "" {
 		return "", errors.New("empty string for whois request")
 	}
+	if result, ok := an.Cache.whois(target); ok {
+		return result, nil
+	}
+	an.WhoisLimiter.Wait()
 	result, err := whois.Whois(target)
 	if err != nil {
 		return "", err
 	}
+	an.Cache.storeWhois(target, result)
 	return result, nil
 }
 
-// GetAllRecords queries for specific DNS record types for a domain.
+// GetAllRecords queries for specific DNS record types for a domain. A failed query for one type does
+// not stop the others, but a domain which does not exist returns the error.
 func (an *DNSAnalyser) GetAllRecords(domain string) ([][]dns.RR, error) {
-	client := new(dns.Client)
 	var results [][]dns.RR
 	recordTypes := []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeMX, dns.TypeTXT, dns.TypeCNAME, dns.TypeNS, dns.TypeSRV}
 
 	for _, recordType := range recordTypes {
-		m := new(dns.Msg)
-		m.SetQuestion(dns.Fqdn(domain), recordType)
-		m.RecursionDesired = true
-
-		r, _, err := client.Exchange(m, resolverIP+":53")
+		r, err := an.initDNSMsg(domain, recordType)
+		if LookupStatus(err) == StatusNXDOMAIN {
+			return results, err
+		}
 		if err != nil {
 			log.Printf("Error querying %s records: %v\n", dns.TypeToString[recordType], err)
-			break
+			continue
 		}
 		if r.Answer != nil {
 			results = append(results, r.Answer)
@@ -53,29 +70,58 @@ func (an *DNSAnalyser) GetAllRecords(domain string) ([][]dns.RR, error) {
 
 // ReverseLookup performs a reverse lookup of domains associated with an IP address.
 func (an *DNSAnalyser) ReverseLookup(ip string) ([]string, error) {
-	names, err := net.LookupAddr(ip)
-	for i := range names {
-		if strings.HasSuffix(names[i], ".") {
-			names[i] = strings.TrimSuffix(names[i], ".")
-		}
-	}
+	arpa, err := dns.ReverseAddr(ip)
 	if err != nil {
 		return nil, err
 	}
+	msg, err := an.initDNSMsg(arpa, dns.TypePTR)
+	if err != nil {
+		return nil, fmt.Errorf("DNS query failed: %w", err)
+	}
+	var names []string
+	for _, ans := range msg.Answer {
+		if ptr, ok := ans.(*dns.PTR); ok {
+			names = append(names, strings.TrimSuffix(ptr.Ptr, "."))
+		}
+	}
+	if len(names) == 0 {
+		return nil, noData(arpa, dns.TypePTR)
+	}
 	return names, nil
 }
 
-// IPLookup returns IP addresses associated with a domain.
+// IPLookup returns IP addresses associated with a domain. When neither query returns an address the
+// error describes why, with a failed query taking precedence over a name without addresses.
 func (an *DNSAnalyser) IPLookup(domain string) ([]net.IP, error) {
 	var res []net.IP
-	ips, err := net.LookupIP(domain)
-	if err != nil {
-		return nil, err
+	var failed error
+	for _, dnsType := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg, err := an.initDNSMsg(domain, dnsType)
+		if LookupStatus(err) == StatusNXDOMAIN {
+			return nil, err
+		}
+		if err != nil {
+			if failed == nil {
+				failed = err
+			}
+			continue
+		}
+		for _, ans := range msg.Answer {
+			switch rr := ans.(type) {
+			case *dns.A:
+				res = append(res, rr.A)
+			case *dns.AAAA:
+				res = append(res, rr.AAAA)
+			}
+		}
 	}
-	for _, ip := range ips {
-		res = append(res, ip)
+	switch {
+	case len(res) > 0:
+		return res, nil
+	case failed != nil:
+		return nil, failed
 	}
-	return res, nil
+	return nil, noData(domain, dns.TypeA)
 }
 
 // GetCNAME gets CNAME records for a domain.
@@ -85,7 +131,7 @@ func (an *DNSAnalyser) GetCNAME(domain string) (string, error) {
 		return "", err
 	}
 
-	r, err := initDNSMsg(domain, dns.TypeCNAME)
+	r, err := an.initDNSMsg(domain, dns.TypeCNAME)
 	if err != nil {
 		return "", fmt.Errorf("DNS query failed: %w", err)
 	}
@@ -98,7 +144,55 @@ func (an *DNSAnalyser) GetCNAME(domain string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("no CNAME record found for %s", hostname)
+	return "", noData(hostname, dns.TypeCNAME)
+}
+
+// maxCNAMEDepth is the longest CNAME chain followed, matching the limit of common resolvers.
+const maxCNAMEDepth = 16
+
+// ResolveCNAMEChain follows the CNAMEs of a hostname one query at a time and looks up the addresses of
+// the last name in the chain. Chains which loop, grow longer than maxCNAMEDepth or end at a name which
+// does not exist return the chain followed so far with the error.
+func (an *DNSAnalyser) ResolveCNAMEChain(hostname string) (models.CNAMEChain, error) {
+	name := strings.ToLower(strings.TrimSuffix(hostname, "."))
+	chain := models.CNAMEChain{Hostname: name}
+	seen := map[string]bool{name: true}
+	for {
+		msg, err := an.initDNSMsg(name, dns.TypeCNAME)
+		if err != nil {
+			chain.Status = LookupStatus(err)
+			return chain, err
+		}
+		target := ""
+		for _, rr := range answerRRs(msg, name, dns.TypeCNAME) {
+			target = strings.ToLower(strings.TrimSuffix(rr.(*dns.CNAME).Target, "."))
+		}
+		if target == "" {
+			break
+		}
+
+		chain.Targets = append(chain.Targets, target)
+		var status string
+		switch {
+		case seen[target]:
+			status = StatusCNAMELoop
+		case len(chain.Targets) > maxCNAMEDepth:
+			status = StatusCNAMEDepth
+		}
+		if status != "" {
+			chain.Status = status
+			return chain, &LookupError{Name: hostname, Type: dns.TypeToString[dns.TypeCNAME], Status: status}
+		}
+		seen[target] = true
+		name = target
+	}
+
+	ips, err := an.IPLookup(name)
+	for _, ip := range ips {
+		chain.Addresses = append(chain.Addresses, ip.String())
+	}
+	chain.Status = LookupStatus(err)
+	return chain, err
 }
 
 // GetTXT gets TXT records for a domain.
@@ -109,7 +203,7 @@ func (an *DNSAnalyser) GetTXT(domain string) ([]string, error) {
 	}
 
 	// Perform the DNS query using the specified resolver
-	msg, err := initDNSMsg(hostname, dns.TypeTXT)
+	msg, err := an.initDNSMsg(hostname, dns.TypeTXT)
 	if err != nil {
 		return nil, fmt.Errorf("DNS query failed: %w", err)
 	}
@@ -125,12 +219,78 @@ func (an *DNSAnalyser) GetTXT(domain string) ([]string, error) {
 	return txtRecords, nil
 }
 
+// GetNS gets the nameservers of a zone.
+func (an *DNSAnalyser) GetNS(zone string) ([]string, error) {
+	msg, err := an.initDNSMsg(zone, dns.TypeNS)
+	if err != nil {
+		return nil, fmt.Errorf("DNS query failed: %w", err)
+	}
+	var nameservers []string
+	for _, ans := range msg.Answer {
+		if ns, ok := ans.(*dns.NS); ok {
+			nameservers = append(nameservers, strings.TrimSuffix(ns.Ns, "."))
+		}
+	}
+	if len(nameservers) == 0 {
+		return nil, noData(zone, dns.TypeNS)
+	}
+	return nameservers, nil
+}
+
+// ZoneApex returns the apex of the zone a domain belongs to, taken from the owner of the SOA record
+// in the answer or, for names below the apex, the authority section.
+func (an *DNSAnalyser) ZoneApex(domain string) (string, error) {
+	msg, err := an.initDNSMsg(domain, dns.TypeSOA)
+	// A name which does not exist still gives the SOA of its zone in the authority section.
+	if err != nil && LookupStatus(err) != StatusNXDOMAIN {
+		return "", fmt.Errorf("DNS query failed: %w", err)
+	}
+	for _, rr := range append(msg.Answer, msg.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return strings.TrimSuffix(soa.Hdr.Name, "."), nil
+		}
+	}
+	return "", fmt.Errorf("no SOA record found for %s", domain)
+}
+
+// CheckZoneTransfer attempts an unauthenticated AXFR of a zone from every address of each of its
+// nameservers. Zones from successful transfers are returned alongside the result for every server.
+func (an *DNSAnalyser) CheckZoneTransfer(zone string) ([]models.ZoneTransferResult, []models.ZoneFile, error) {
+	nameservers, err := an.GetNS(zone)
+	if err != nil {
+		return nil, nil, err
+	}
+	var results []models.ZoneTransferResult
+	var leaked []models.ZoneFile
+	dz := zone_files.DNSZones{}
+	for _, ns := range nameservers {
+		addrs, err := an.IPLookup(ns)
+		if err != nil {
+			results = append(results, models.ZoneTransferResult{Zone: zone, Nameserver: ns, Error: err.Error()})
+			continue
+		}
+		for _, addr := range addrs {
+			res := models.ZoneTransferResult{Zone: zone, Nameserver: ns, Address: addr.String()}
+			zf, err := dz.TransferZone(zone_files.ZoneTransfer{Zone: zone, Server: addr.String(), Timeout: 5 * time.Second})
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Allowed = true
+				res.Records = len(zf.Records)
+				leaked = append(leaked, zf)
+			}
+			results = append(results, res)
+		}
+	}
+	return results, leaked, nil
+}
+
 // DNSSECEnabled returns a boolean based on whether DNSSEC is enabled on a domain.
 func (an *DNSAnalyser) DNSSECEnabled(domain string) (bool, error) {
 	if strings.HasSuffix(domain, ".") {
 		domain = strings.TrimSuffix(domain, ".")
 	}
-	msg, err := initDNSMsg(domain, dns.TypeDS)
+	msg, err := an.initDNSMsg(domain, dns.TypeDS)
 	if err != nil {
 		return false, err
 	}
@@ -213,15 +373,37 @@ func (an *DNSAnalyser) ParseWHOIS(input string) *models.WHOISRecord {
 	return &record
 }
 
-func initDNSMsg(domain string, dnsType uint16) (*dns.Msg, error) {
+// initDNSMsg sends a recursive query for a domain through the analyser's resolver. Failed exchanges
+// and responses other than NOERROR return a LookupError alongside any response received. Responses
+// still within their TTL are answered from the cache.
+func (an *DNSAnalyser) initDNSMsg(domain string, dnsType uint16) (*dns.Msg, error) {
 	m := new(dns.Msg)
-	c := new(dns.Client)
 	m.SetQuestion(dns.Fqdn(domain), dnsType)
 	m.RecursionDesired = true
+	resolver := an.resolver()
+	if msg, ok := an.Cache.message(resolver, m); ok {
+		return msg, classify(domain, dnsType, msg, nil)
+	}
 
-	msg, _, err := c.Exchange(m, resolverIP+":53")
-	if err != nil {
-		return msg, err
+	msg, err := resolver.Exchange(m)
+	if err == nil {
+		an.Cache.storeMessage(resolver, m, msg)
+	}
+	return msg, classify(domain, dnsType, msg, err)
+}
+
+// resolver returns the analyser's resolver, or one for 8.8.8.8 when none is configured.
+func (an *DNSAnalyser) resolver() Resolver {
+	if an.Resolver == nil {
+		return &UpstreamResolver{Servers: []string{defaultResolverIP}}
+	}
+	return an.Resolver
+}
+
+// nameservers returns the resolver for querying nameservers directly.
+func (an *DNSAnalyser) nameservers() *UpstreamResolver {
+	if an.Nameservers == nil {
+		return &UpstreamResolver{}
 	}
-	return msg, nil
+	return an.Nameservers
 }
@@ -1,7 +1,11 @@
 package dns_analysers
 
 import (
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 var (
@@ -57,3 +61,68 @@ func TestDNSSECEnabled(t *testing.T) {
 		t.Errorf("Checking DNSSEC failed: %v", err)
 	}
 }
+
+func TestResolveCNAMEChain(t *testing.T) {
+	records := []string{
+		"www.example.com. 60 IN CNAME www.example.com.cdn.example.net.",
+		"www.example.com.cdn.example.net. 60 IN CNAME edge.example.org.",
+		"edge.example.org. 60 IN A 192.0.2.10",
+		"dangling.example.com. 60 IN CNAME gone.example.net.",
+		"loop-a.example.com. 60 IN CNAME loop-b.example.com.",
+		"loop-b.example.com. 60 IN CNAME LOOP-A.example.com.",
+	}
+	// Names without any records do not exist.
+	addr := serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := recordAnswer(t, r, records...)
+		m.Rcode = dns.RcodeNameError
+		for _, s := range records {
+			if rr, _ := dns.NewRR(s); dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(r.Question[0].Name) {
+				m.Rcode = dns.RcodeSuccess
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+	an := &DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{addr}, Timeout: time.Second}}
+
+	t.Run("Chains are followed to the addresses of the last name.", func(t *testing.T) {
+		chain, err := an.ResolveCNAMEChain("WWW.example.com.")
+		assert.NoError(t, err)
+		assert.Equal(t, "www.example.com", chain.Hostname)
+		assert.Equal(t, []string{"www.example.com.cdn.example.net", "edge.example.org"}, chain.Targets)
+		assert.Equal(t, []string{"192.0.2.10"}, chain.Addresses)
+		assert.Equal(t, StatusOK, chain.Status)
+	})
+
+	t.Run("Names which are not aliases have an empty chain.", func(t *testing.T) {
+		chain, err := an.ResolveCNAMEChain("edge.example.org")
+		assert.NoError(t, err)
+		assert.Empty(t, chain.Targets)
+		assert.Equal(t, []string{"192.0.2.10"}, chain.Addresses)
+	})
+
+	t.Run("Chains ending at a name which does not exist are dangling.", func(t *testing.T) {
+		chain, err := an.ResolveCNAMEChain("dangling.example.com")
+		assert.Equal(t, StatusNXDOMAIN, LookupStatus(err))
+		assert.Equal(t, []string{"gone.example.net"}, chain.Targets)
+		assert.Equal(t, StatusNXDOMAIN, chain.Status)
+	})
+
+	t.Run("Loops are detected.", func(t *testing.T) {
+		chain, err := an.ResolveCNAMEChain("loop-a.example.com")
+		assert.ErrorContains(t, err, "loops")
+		assert.Equal(t, []string{"loop-b.example.com", "loop-a.example.com"}, chain.Targets)
+		assert.Equal(t, StatusCNAMELoop, chain.Status)
+	})
+
+	t.Run("Chains longer than the maximum depth are cut off.", func(t *testing.T) {
+		deepRecords := make([]string, maxCNAMEDepth+2)
+		for i := range deepRecords {
+			deepRecords[i] = fmt.Sprintf("deep%d.example.com. 60 IN CNAME deep%d.example.com.", i, i+1)
+		}
+		deep := &DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{serveRecords(t, deepRecords...)}, Timeout: time.Second}}
+		chain, err := deep.ResolveCNAMEChain("deep0.example.com")
+		assert.Equal(t, StatusCNAMEDepth, LookupStatus(err))
+		assert.Len(t, chain.Targets, maxCNAMEDepth+1)
+		assert.Equal(t, StatusCNAMEDepth, chain.Status)
+	})
+}
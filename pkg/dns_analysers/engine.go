@@ -0,0 +1,80 @@
+package dns_analysers
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// defaultWorkers is the number of concurrent lookups made when a LookupEngine does not set one.
+const defaultWorkers = 10
+
+// LookupEngine runs DNS and WHOIS lookups for many targets on a bounded pool of workers. Query rates
+// are limited per server by UpstreamResolver.QPS and for WHOIS by DNSAnalyser.WhoisLimiter.
+type LookupEngine struct {
+	// Workers is the number of lookups run at once.
+	Workers int
+	// Jitter is the upper bound of a random delay before the lookup of each target, which spreads
+	// queries out rather than sending them in bursts.
+	Jitter time.Duration
+}
+
+// RunLookups calls lookup for every target on the engine's workers and returns the results in the
+// order of the targets. Results are merged by the caller after every lookup has finished, so they can
+// be added to an assessment without further locking.
+func RunLookups[T any](le *LookupEngine, targets []string, lookup func(target string) T) []T {
+	results := make([]T, len(targets))
+	workers := le.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if le.Jitter > 0 {
+					time.Sleep(time.Duration(rand.Int63n(int64(le.Jitter))))
+				}
+				results[i] = lookup(targets[i])
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// RateLimiter spaces out queries so no more than QPS of them are sent per second, however many workers
+// send them. A nil RateLimiter or a QPS of zero does not limit.
+type RateLimiter struct {
+	QPS float64
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// Wait blocks until the next query may be sent without exceeding the limit.
+func (rl *RateLimiter) Wait() {
+	if rl == nil || rl.QPS <= 0 {
+		return
+	}
+	rl.mu.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	delay := rl.next.Sub(now)
+	rl.next = rl.next.Add(time.Duration(float64(time.Second) / rl.QPS))
+	rl.mu.Unlock()
+	time.Sleep(delay)
+}
@@ -0,0 +1,110 @@
+package dns_analysers
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunLookups(t *testing.T) {
+	targets := make([]string, 50)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("host%d.example.com", i)
+	}
+
+	t.Run("Results are returned in the order of the targets.", func(t *testing.T) {
+		le := LookupEngine{Workers: 8}
+		results := RunLookups(&le, targets, func(target string) string {
+			return strings.ToUpper(target)
+		})
+		assert.Len(t, results, len(targets))
+		for i := range targets {
+			assert.Equal(t, strings.ToUpper(targets[i]), results[i])
+		}
+	})
+
+	t.Run("No more lookups than workers run at once.", func(t *testing.T) {
+		var running, peak int32
+		le := LookupEngine{Workers: 4}
+		RunLookups(&le, targets, func(target string) bool {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return true
+		})
+		assert.LessOrEqual(t, peak, int32(4))
+		assert.Greater(t, peak, int32(1))
+	})
+
+	t.Run("No targets run no lookups.", func(t *testing.T) {
+		le := LookupEngine{}
+		assert.Empty(t, RunLookups(&le, nil, func(target string) int { return 1 }))
+	})
+}
+
+func TestUpstreamResolverQPS(t *testing.T) {
+	addr := serveRecords(t, "www.example.com. 60 IN A 192.0.2.10")
+
+	t.Run("Queries to a resolver are spaced to its QPS limit across workers.", func(t *testing.T) {
+		an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{addr}, Timeout: time.Second, QPS: 100}}
+		le := LookupEngine{Workers: 5, Jitter: time.Millisecond}
+		targets := make([]string, 10)
+		for i := range targets {
+			targets[i] = "www.example.com"
+		}
+
+		start := time.Now()
+		errs := RunLookups(&le, targets, func(target string) error {
+			_, err := an.GetTXT(target)
+			return err
+		})
+		// Ten queries at 100 per second need at least 90ms between the first and the last.
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("Each server of a resolver has its own limit.", func(t *testing.T) {
+		failing := serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeServerFailure)
+			_ = w.WriteMsg(m)
+		})
+		ur := &UpstreamResolver{Servers: []string{failing, addr}, Timeout: time.Second, QPS: 100}
+		an := DNSAnalyser{Resolver: ur}
+		for i := 0; i < 3; i++ {
+			_, err := an.IPLookup("www.example.com")
+			assert.NoError(t, err)
+		}
+		assert.Len(t, ur.limiters, 2)
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Queries are spaced to the limit.", func(t *testing.T) {
+		rl := &RateLimiter{QPS: 50}
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			rl.Wait()
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("A nil limiter does not wait.", func(t *testing.T) {
+		var rl *RateLimiter
+		start := time.Now()
+		rl.Wait()
+		assert.Less(t, time.Since(start), 10*time.Millisecond)
+	})
+}
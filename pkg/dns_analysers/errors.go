@@ -0,0 +1,100 @@
+package dns_analysers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+)
+
+// Lookup statuses recorded for each domain.
+const (
+	StatusOK       = "ok"
+	StatusNXDOMAIN = "nxdomain"
+	StatusNODATA   = "nodata"
+	StatusSERVFAIL = "servfail"
+	StatusREFUSED  = "refused"
+	StatusTimeout  = "timeout"
+	StatusError    = "error"
+	// StatusCNAMELoop and StatusCNAMEDepth end CNAME chains which loop or are too long to follow.
+	StatusCNAMELoop  = "cname-loop"
+	StatusCNAMEDepth = "cname-depth"
+)
+
+// LookupError describes a query which did not return the records asked for.
+type LookupError struct {
+	Name   string
+	Type   string
+	Status string
+	Err    error
+}
+
+func (e *LookupError) Error() string {
+	msg := fmt.Sprintf("lookup %s %s: ", e.Name, e.Type)
+	switch e.Status {
+	case StatusNXDOMAIN:
+		return msg + "domain does not exist"
+	case StatusNODATA:
+		return msg + "no records found"
+	case StatusSERVFAIL:
+		return msg + "server failure"
+	case StatusREFUSED:
+		return msg + "query refused"
+	case StatusCNAMELoop:
+		return msg + "CNAME chain loops"
+	case StatusCNAMEDepth:
+		return fmt.Sprintf("%sCNAME chain is longer than %d", msg, maxCNAMEDepth)
+	}
+	return msg + e.Err.Error()
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the same query may succeed if it is retried later. UpstreamResolver only
+// retries queries which fail temporarily.
+func (e *LookupError) Temporary() bool {
+	return e.Status == StatusSERVFAIL || e.Status == StatusTimeout || e.Status == StatusError
+}
+
+// LookupStatus returns the status of a lookup from the error it returned.
+func LookupStatus(err error) string {
+	if err == nil {
+		return StatusOK
+	}
+	var le *LookupError
+	if errors.As(err, &le) {
+		return le.Status
+	}
+	return StatusError
+}
+
+// classify returns a LookupError for a failed exchange or a response code other than NOERROR.
+func classify(domain string, dnsType uint16, msg *dns.Msg, err error) error {
+	le := &LookupError{Name: domain, Type: dns.TypeToString[dnsType], Err: err}
+	var ne net.Error
+	switch {
+	case err != nil && errors.As(err, &ne) && ne.Timeout():
+		le.Status = StatusTimeout
+	case err != nil:
+		le.Status = StatusError
+	case msg.Rcode == dns.RcodeSuccess:
+		return nil
+	case msg.Rcode == dns.RcodeNameError:
+		le.Status = StatusNXDOMAIN
+	case msg.Rcode == dns.RcodeServerFailure:
+		le.Status = StatusSERVFAIL
+	case msg.Rcode == dns.RcodeRefused:
+		le.Status = StatusREFUSED
+	default:
+		le.Status = StatusError
+		le.Err = fmt.Errorf("response code %s", dns.RcodeToString[msg.Rcode])
+	}
+	return le
+}
+
+// noData returns the error for a name which exists but has no records of the type asked for.
+func noData(domain string, dnsType uint16) error {
+	return &LookupError{Name: domain, Type: dns.TypeToString[dnsType], Status: StatusNODATA}
+}
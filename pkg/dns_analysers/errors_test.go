@@ -0,0 +1,111 @@
+package dns_analysers
+
+import (
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLookupErrors(t *testing.T) {
+	var flaky, refused int32
+	addr := serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		answer := func(s string) {
+			if rr, _ := dns.NewRR(s); rr != nil && rr.Header().Rrtype == q.Qtype {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		switch q.Name {
+		case "missing.example.com.":
+			m.Rcode = dns.RcodeNameError
+		case "refused.example.com.":
+			atomic.AddInt32(&refused, 1)
+			m.Rcode = dns.RcodeRefused
+		case "flaky.example.com.":
+			if atomic.AddInt32(&flaky, 1) <= 2 {
+				m.Rcode = dns.RcodeServerFailure
+			} else {
+				answer("flaky.example.com. 60 IN A 192.0.2.20")
+			}
+		case "big.example.com.":
+			if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+				m.Truncated = true
+			} else {
+				answer("big.example.com. 60 IN A 192.0.2.30")
+			}
+		case "txt.example.com.":
+			answer(`txt.example.com. 60 IN TXT "only text"`)
+		}
+		_ = w.WriteMsg(m)
+	})
+	an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{addr}, Timeout: time.Second}}
+
+	t.Run("Response codes and missing records are classified.", func(t *testing.T) {
+		_, err := an.IPLookup("missing.example.com")
+		assert.Equal(t, StatusNXDOMAIN, LookupStatus(err))
+		_, err = an.IPLookup("refused.example.com")
+		assert.Equal(t, StatusREFUSED, LookupStatus(err))
+		_, err = an.IPLookup("txt.example.com")
+		assert.Equal(t, StatusNODATA, LookupStatus(err))
+		assert.EqualError(t, err, "lookup txt.example.com A: no records found")
+		assert.Equal(t, StatusOK, LookupStatus(nil))
+	})
+
+	t.Run("SERVFAIL is retried until the server recovers.", func(t *testing.T) {
+		_, err := an.IPLookup("flaky.example.com")
+		assert.Equal(t, StatusSERVFAIL, LookupStatus(err))
+
+		atomic.StoreInt32(&flaky, 0)
+		retrying := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{addr}, Timeout: time.Second,
+			Retries: 2, Backoff: time.Millisecond}}
+		ips, err := retrying.IPLookup("flaky.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "192.0.2.20", ips[0].String())
+	})
+
+	t.Run("Failures which are not temporary are not retried.", func(t *testing.T) {
+		atomic.StoreInt32(&refused, 0)
+		retrying := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{addr}, Timeout: time.Second,
+			Retries: 2, Backoff: time.Millisecond}}
+		_, err := retrying.initDNSMsg("refused.example.com", dns.TypeA)
+		assert.Equal(t, StatusREFUSED, LookupStatus(err))
+		var le *LookupError
+		assert.ErrorAs(t, err, &le)
+		assert.False(t, le.Temporary())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&refused))
+	})
+
+	t.Run("Truncated responses are repeated over TCP.", func(t *testing.T) {
+		ips, err := an.IPLookup("big.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "192.0.2.30", ips[0].String())
+	})
+
+	t.Run("Servers which do not respond time out.", func(t *testing.T) {
+		silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer silent.Close()
+
+		an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{silent.LocalAddr().String()},
+			Timeout: 50 * time.Millisecond, Retries: 1}}
+		_, err = an.IPLookup("www.example.com")
+		assert.Equal(t, StatusTimeout, LookupStatus(err))
+		var le *LookupError
+		assert.ErrorAs(t, err, &le)
+		assert.True(t, le.Temporary())
+	})
+
+	t.Run("Every record type is queried unless the domain does not exist.", func(t *testing.T) {
+		records, err := an.GetAllRecords("txt.example.com")
+		assert.NoError(t, err)
+		assert.Len(t, records, 1)
+
+		_, err = an.GetAllRecords("missing.example.com")
+		assert.Equal(t, StatusNXDOMAIN, LookupStatus(err))
+	})
+}
@@ -0,0 +1,291 @@
+package dns_analysers
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver sends a DNS query to an upstream and returns its response.
+type Resolver interface {
+	Exchange(msg *dns.Msg) (*dns.Msg, error)
+}
+
+// resolvConf is the file the system resolvers are read from.
+var resolvConf = "/etc/resolv.conf"
+
+// defaultResolverIP is queried when a DNSAnalyser has no resolver configured.
+const defaultResolverIP = "8.8.8.8"
+
+// ResolverOptions are applied to every list of upstreams a resolver is created with.
+type ResolverOptions struct {
+	Timeout time.Duration
+	QPS     float64
+	Retries int
+	Backoff time.Duration
+	// DoHMethod is the HTTP method of DNS-over-HTTPS queries, GET or POST. Defaults to POST.
+	DoHMethod string
+}
+
+// UpstreamResolver sends queries to a list of servers in turn until one of them responds.
+type UpstreamResolver struct {
+	// Servers are addresses in the form host[:port], queried over UDP on port 53 by default,
+	// tls://host[:port][#name] for DNS-over-TLS or https:// URLs for DNS-over-HTTPS.
+	Servers []string
+	Timeout time.Duration
+	// QPS limits the queries sent per second to each of the servers. Zero means no limit.
+	QPS float64
+	// Retries is the number of times the servers are tried again after timeouts, network errors or
+	// SERVFAIL responses, waiting Backoff before the first retry and doubling it for each one after.
+	Retries int
+	Backoff time.Duration
+	// DoHMethod is the HTTP method of queries to DNS-over-HTTPS servers, GET or POST. Defaults to POST.
+	DoHMethod string
+	// TLSConfig verifies DNS-over-TLS and DNS-over-HTTPS servers. The system roots are used when nil.
+	TLSConfig *tls.Config
+
+	mu         sync.Mutex
+	limiters   map[string]*RateLimiter
+	clientOnce sync.Once
+	client     *http.Client
+}
+
+// Exchange sends a query to each server in order and returns the first response which is neither
+// SERVFAIL nor REFUSED. When every server fails the last such response is returned, or the errors of
+// the last attempt when no server responded.
+func (ur *UpstreamResolver) Exchange(msg *dns.Msg) (*dns.Msg, error) {
+	return ur.exchangeServers(msg, ur.Servers)
+}
+
+// ExchangeWith sends a query to a server which need not be one of the resolver's servers, i.e., an
+// authoritative nameserver, with the resolver's timeout, retries and rate limit.
+func (ur *UpstreamResolver) ExchangeWith(msg *dns.Msg, server string) (*dns.Msg, error) {
+	return ur.exchangeServers(msg, []string{server})
+}
+
+func (ur *UpstreamResolver) exchangeServers(msg *dns.Msg, servers []string) (*dns.Msg, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("no upstream resolvers configured")
+	}
+	var last *dns.Msg
+	for attempt := 0; ; attempt++ {
+		var errs []error
+		transient := false
+		for _, server := range servers {
+			r, err := ur.exchange(msg, server)
+			// Responses other than SERVFAIL and REFUSED are answers, even when the name does not exist.
+			le, failed := classify("", dns.TypeNone, r, err).(*LookupError)
+			switch {
+			case !failed || (err == nil && le.Status != StatusSERVFAIL && le.Status != StatusREFUSED):
+				return r, nil
+			case err != nil:
+				errs = append(errs, fmt.Errorf("%s: %w", server, err))
+			default:
+				last = r
+			}
+			transient = transient || le.Temporary()
+		}
+		if !transient || attempt >= ur.Retries {
+			if last != nil {
+				return last, nil
+			}
+			return nil, errors.Join(errs...)
+		}
+		time.Sleep(ur.Backoff << attempt)
+	}
+}
+
+// exchange sends a query to one server over UDP, repeating it over TCP when the response is truncated,
+// or over the encrypted transport the server's scheme selects.
+func (ur *UpstreamResolver) exchange(msg *dns.Msg, server string) (*dns.Msg, error) {
+	switch {
+	case strings.HasPrefix(server, tlsScheme):
+		return ur.exchangeTLS(msg, server)
+	case strings.HasPrefix(server, httpsScheme):
+		return ur.exchangeHTTPS(msg, server)
+	}
+	client := &dns.Client{Timeout: ur.Timeout}
+	ur.wait(server)
+	r, _, err := client.Exchange(msg, serverAddress(server))
+	if err != nil || !r.Truncated {
+		return r, err
+	}
+	client.Net = "tcp"
+	ur.wait(server)
+	r, _, err = client.Exchange(msg, serverAddress(server))
+	return r, err
+}
+
+// wait blocks until the next query may be sent to a server without exceeding its QPS limit.
+func (ur *UpstreamResolver) wait(server string) {
+	if ur.QPS <= 0 {
+		return
+	}
+	ur.mu.Lock()
+	if ur.limiters == nil {
+		ur.limiters = make(map[string]*RateLimiter)
+	}
+	limiter, ok := ur.limiters[server]
+	if !ok {
+		limiter = &RateLimiter{QPS: ur.QPS}
+		ur.limiters[server] = limiter
+	}
+	ur.mu.Unlock()
+	limiter.Wait()
+}
+
+// SystemResolver returns a resolver for the nameservers the operating system is configured to use.
+func SystemResolver() (*UpstreamResolver, error) {
+	conf, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil {
+		return nil, fmt.Errorf("reading system resolvers: %w", err)
+	}
+	ur := &UpstreamResolver{Timeout: time.Duration(conf.Timeout) * time.Second}
+	for _, server := range conf.Servers {
+		ur.Servers = append(ur.Servers, net.JoinHostPort(server, conf.Port))
+	}
+	return ur, nil
+}
+
+// ZoneResolver routes queries for names within a zone to the resolver configured for it, i.e., an
+// organisation's internal resolvers for its internal zones, and every other query to the default.
+type ZoneResolver struct {
+	Default Resolver
+	Zones   map[string]Resolver
+}
+
+// Exchange sends a query to the resolver of the longest zone containing the queried name.
+func (zr *ZoneResolver) Exchange(msg *dns.Msg) (*dns.Msg, error) {
+	return zr.resolverFor(msg).Exchange(msg)
+}
+
+func (zr *ZoneResolver) resolverFor(msg *dns.Msg) Resolver {
+	if len(msg.Question) == 0 {
+		return zr.Default
+	}
+	name := strings.ToLower(dns.Fqdn(msg.Question[0].Name))
+	for i, end := 0, false; !end; i, end = dns.NextLabel(name, i) {
+		if r, ok := zr.Zones[strings.TrimSuffix(name[i:], ".")]; ok {
+			return r
+		}
+	}
+	return zr.Default
+}
+
+// NewResolver creates a resolver from a list of upstreams, each either 'system' for the operating
+// system's resolvers, a host[:port] address, a tls://host[:port][#name] DNS-over-TLS server or an
+// https:// DNS-over-HTTPS URL, and a list of per-zone overrides in the form
+// zone@host[:port]. Overrides for the same zone are tried in the order given. Without upstreams
+// queries are sent to 8.8.8.8.
+func NewResolver(upstreams, zoneUpstreams []string, opts ResolverOptions) (Resolver, error) {
+	if opts.DoHMethod != "" && !strings.EqualFold(opts.DoHMethod, http.MethodGet) && !strings.EqualFold(opts.DoHMethod, http.MethodPost) {
+		return nil, fmt.Errorf("invalid DoH method %q, expected GET or POST", opts.DoHMethod)
+	}
+	def, err := upstreamResolver(upstreams)
+	if err != nil {
+		return nil, err
+	}
+	def.apply(opts)
+	if len(zoneUpstreams) == 0 {
+		return def, nil
+	}
+
+	zr := &ZoneResolver{Default: def, Zones: make(map[string]Resolver)}
+	servers := make(map[string][]string)
+	var zones []string
+	for _, entry := range zoneUpstreams {
+		zone, server, ok := strings.Cut(entry, "@")
+		if !ok || zone == "" || server == "" {
+			return nil, fmt.Errorf("invalid zone resolver %q, expected zone@host[:port]", entry)
+		}
+		zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+		if _, ok := servers[zone]; !ok {
+			zones = append(zones, zone)
+		}
+		servers[zone] = append(servers[zone], server)
+	}
+	for _, zone := range zones {
+		r, err := upstreamResolver(servers[zone])
+		if err != nil {
+			return nil, err
+		}
+		r.apply(opts)
+		zr.Zones[zone] = r
+	}
+	return zr, nil
+}
+
+// NewNameserverResolver creates a resolver for querying nameservers directly with the same options as
+// the resolvers NewResolver creates. Queries are sent with ExchangeWith.
+func NewNameserverResolver(opts ResolverOptions) *UpstreamResolver {
+	ur := &UpstreamResolver{}
+	ur.apply(opts)
+	return ur
+}
+
+func (ur *UpstreamResolver) apply(opts ResolverOptions) {
+	if opts.Timeout > 0 {
+		ur.Timeout = opts.Timeout
+	}
+	ur.QPS = opts.QPS
+	ur.Retries = opts.Retries
+	ur.Backoff = opts.Backoff
+	ur.DoHMethod = opts.DoHMethod
+}
+
+// upstreamResolver combines a list of addresses and 'system' entries into one list of servers.
+func upstreamResolver(upstreams []string) (*UpstreamResolver, error) {
+	if len(upstreams) == 0 {
+		return &UpstreamResolver{Servers: []string{defaultResolverIP}}, nil
+	}
+	ur := &UpstreamResolver{}
+	for _, upstream := range upstreams {
+		if upstream == "system" {
+			sys, err := SystemResolver()
+			if err != nil {
+				return nil, err
+			}
+			ur.Servers = append(ur.Servers, sys.Servers...)
+			ur.Timeout = sys.Timeout
+			continue
+		}
+		if !validServerAddress(upstream) {
+			return nil, fmt.Errorf("invalid resolver address %q, expected host[:port], tls://host[:port] or an https URL", upstream)
+		}
+		ur.Servers = append(ur.Servers, upstream)
+	}
+	return ur, nil
+}
+
+// serverAddress adds the default DNS port to an address which does not give one.
+func serverAddress(server string) string {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	return server
+}
+
+// validServerAddress reports whether an address has a host and, when given, a valid port. Hosts
+// containing ':' must be IPv6 addresses. DoH servers must be https URLs.
+func validServerAddress(server string) bool {
+	if strings.HasPrefix(server, httpsScheme) {
+		return validHTTPSServer(server)
+	}
+	if strings.HasPrefix(server, tlsScheme) {
+		addr, name := tlsAddress(server)
+		return name != "" && validServerAddress(addr)
+	}
+	host, port, err := net.SplitHostPort(serverAddress(server))
+	if err != nil || host == "" || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return false
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 1<<16
+}
@@ -0,0 +1,123 @@
+package dns_analysers
+
+import (
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serveRecords starts a local server which answers queries from a list of records.
+func serveRecords(t *testing.T, records ...string) string {
+	var rrs []dns.RR
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		assert.NoError(t, err)
+		rrs = append(rrs, rr)
+	}
+	return serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		for _, rr := range rrs {
+			if dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(q.Name) && rr.Header().Rrtype == q.Qtype {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+}
+
+// serveDNS starts a local server on the same UDP and TCP port and returns its address.
+func serveDNS(t *testing.T, handler dns.HandlerFunc) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	assert.NoError(t, err)
+
+	for _, srv := range []*dns.Server{{PacketConn: conn, Handler: handler}, {Listener: listener, Handler: handler}} {
+		srv := srv
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go func() { _ = srv.ActivateAndServe() }()
+		<-started
+		t.Cleanup(func() { _ = srv.Shutdown() })
+	}
+	return conn.LocalAddr().String()
+}
+
+// closedAddress returns a local address nothing is listening on.
+func closedAddress(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := conn.LocalAddr().String()
+	assert.NoError(t, conn.Close())
+	return addr
+}
+
+func TestResolver(t *testing.T) {
+	public := serveRecords(t,
+		"www.example.com. 60 IN A 192.0.2.10",
+		"www.example.com. 60 IN AAAA 2001:db8::10",
+		"10.2.0.192.in-addr.arpa. 60 IN PTR www.example.com.",
+	)
+	internal := serveRecords(t, "app.corp.example. 60 IN A 10.0.0.5")
+
+	t.Run("Every lookup uses the configured resolver.", func(t *testing.T) {
+		an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{public}, Timeout: time.Second}}
+		ips, err := an.IPLookup("www.example.com")
+		assert.NoError(t, err)
+		assert.Len(t, ips, 2)
+		assert.Equal(t, "192.0.2.10", ips[0].String())
+		assert.Equal(t, "2001:db8::10", ips[1].String())
+
+		names, err := an.ReverseLookup("192.0.2.10")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"www.example.com"}, names)
+
+		_, err = an.IPLookup("missing.example.com")
+		assert.Error(t, err)
+	})
+
+	t.Run("Upstreams are tried in order until one responds.", func(t *testing.T) {
+		an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{closedAddress(t), public}, Timeout: time.Second}}
+		ips, err := an.IPLookup("www.example.com")
+		assert.NoError(t, err)
+		assert.Len(t, ips, 2)
+	})
+
+	t.Run("Zone overrides send queries within a zone to their own resolvers.", func(t *testing.T) {
+		resolver, err := NewResolver([]string{public}, []string{"corp.example@" + internal}, ResolverOptions{})
+		assert.NoError(t, err)
+		an := DNSAnalyser{Resolver: resolver}
+
+		ips, err := an.IPLookup("app.corp.example")
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.5", ips[0].String())
+
+		ips, err = an.IPLookup("www.example.com")
+		assert.NoError(t, err)
+		assert.Len(t, ips, 2)
+	})
+
+	t.Run("The system resolvers are read from resolv.conf.", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resolv.conf")
+		assert.NoError(t, os.WriteFile(path, []byte("nameserver 192.0.2.53\nnameserver 2001:db8::53\n"), 0o644))
+		defer func(conf string) { resolvConf = conf }(resolvConf)
+		resolvConf = path
+
+		resolver, err := NewResolver([]string{"system", "192.0.2.1:5353"}, nil, ResolverOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"192.0.2.53:53", "[2001:db8::53]:53", "192.0.2.1:5353"}, resolver.(*UpstreamResolver).Servers)
+	})
+
+	t.Run("Malformed resolvers are rejected.", func(t *testing.T) {
+		_, err := NewResolver([]string{"192.0.2.1:53:53"}, nil, ResolverOptions{})
+		assert.Error(t, err)
+		_, err = NewResolver(nil, []string{"corp.example"}, ResolverOptions{})
+		assert.ErrorContains(t, err, "zone@host")
+	})
+}
@@ -0,0 +1,137 @@
+package dns_analysers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"github.com/miekg/dns"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// tlsScheme marks a DNS-over-TLS server (RFC 7858), i.e., tls://1.1.1.1#cloudflare-dns.com.
+	tlsScheme = "tls://"
+	// httpsScheme marks a DNS-over-HTTPS server (RFC 8484), i.e., https://dns.google/dns-query.
+	httpsScheme = "https://"
+
+	dotPort         = "853"
+	dnsMessageMedia = "application/dns-message"
+	// maxDNSMessage is the largest DNS message, which bounds the DoH responses read.
+	maxDNSMessage = 65535
+	// defaultDoHTimeout bounds a DoH request when the resolver has no timeout.
+	defaultDoHTimeout = 5 * time.Second
+)
+
+// exchangeTLS sends a query over a TLS connection to a server given as tls://host[:port][#name]. The
+// certificate is verified against the name after '#', or the host when there is none.
+func (ur *UpstreamResolver) exchangeTLS(msg *dns.Msg, server string) (*dns.Msg, error) {
+	addr, name := tlsAddress(server)
+	config := &tls.Config{}
+	if ur.TLSConfig != nil {
+		config = ur.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = name
+	}
+	client := &dns.Client{Net: "tcp-tls", Timeout: ur.Timeout, TLSConfig: config}
+	ur.wait(server)
+	r, _, err := client.Exchange(msg, addr)
+	return r, err
+}
+
+// exchangeHTTPS sends a query to a DoH server with a POST of the message, or a GET with the message in
+// the dns parameter when DoHMethod is GET. The message ID is sent as zero so responses can be cached by
+// HTTP caches, as RFC 8484 recommends, and restored on the response.
+func (ur *UpstreamResolver) exchangeHTTPS(msg *dns.Msg, server string) (*dns.Msg, error) {
+	query := msg.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var req *http.Request
+	if strings.EqualFold(ur.DoHMethod, http.MethodGet) {
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, err
+		}
+		params := u.Query()
+		params.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+		u.RawQuery = params.Encode()
+		req, err = http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		req, err = http.NewRequest(http.MethodPost, server, bytes.NewReader(packed))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", dnsMessageMedia)
+	}
+	req.Header.Set("Accept", dnsMessageMedia)
+
+	ur.wait(server)
+	resp, err := ur.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	if media := resp.Header.Get("Content-Type"); !strings.HasPrefix(media, dnsMessageMedia) {
+		return nil, fmt.Errorf("unexpected content type %q", media)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDNSMessage))
+	if err != nil {
+		return nil, err
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(body); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	r.Id = msg.Id
+	return r, nil
+}
+
+// httpClient returns the client shared by every DoH query of the resolver, so connections are reused.
+func (ur *UpstreamResolver) httpClient() *http.Client {
+	ur.clientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = ur.TLSConfig
+		transport.ForceAttemptHTTP2 = true
+		timeout := ur.Timeout
+		if timeout <= 0 {
+			timeout = defaultDoHTimeout
+		}
+		ur.client = &http.Client{Timeout: timeout, Transport: transport}
+	})
+	return ur.client
+}
+
+// tlsAddress splits a tls:// server into the address to connect to, on port 853 by default, and the
+// name its certificate is verified against.
+func tlsAddress(server string) (string, string) {
+	addr, name, _ := strings.Cut(strings.TrimPrefix(server, tlsScheme), "#")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), dotPort)
+	}
+	if name == "" {
+		name, _, _ = net.SplitHostPort(addr)
+	}
+	return addr, name
+}
+
+// validHTTPSServer reports whether a DoH server is an https URL with a host.
+func validHTTPSServer(server string) bool {
+	u, err := url.Parse(server)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
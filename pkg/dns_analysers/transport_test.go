@@ -0,0 +1,152 @@
+package dns_analysers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// recordAnswer replies to a query from a list of records.
+func recordAnswer(t *testing.T, r *dns.Msg, records ...string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		assert.NoError(t, err)
+		if dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(r.Question[0].Name) && rr.Header().Rrtype == r.Question[0].Qtype {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	return m
+}
+
+// serveDoH starts a local DNS-over-HTTPS server and reports the method and ID of each query it receives.
+func serveDoH(t *testing.T, queries chan<- *http.Request, ids chan<- uint16, records ...string) *httptest.Server {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var packed []byte
+		var err error
+		if req.Method == http.MethodGet {
+			packed, err = base64.RawURLEncoding.DecodeString(req.URL.Query().Get("dns"))
+		} else {
+			assert.Equal(t, dnsMessageMedia, req.Header.Get("Content-Type"))
+			packed, err = io.ReadAll(req.Body)
+		}
+		assert.NoError(t, err)
+		r := new(dns.Msg)
+		if err := r.Unpack(packed); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		queries <- req
+		ids <- r.Id
+		resp, err := recordAnswer(t, r, records...).Pack()
+		assert.NoError(t, err)
+		w.Header().Set("Content-Type", dnsMessageMedia)
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serveDoT starts a local DNS-over-TLS server with the certificate of a test HTTPS server.
+func serveDoT(t *testing.T, certs []tls.Certificate, records ...string) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certs})
+	assert.NoError(t, err)
+	srv := &dns.Server{Listener: listener, Net: "tcp-tls", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		_ = w.WriteMsg(recordAnswer(t, r, records...))
+	})}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return listener.Addr().String()
+}
+
+func TestEncryptedTransports(t *testing.T) {
+	records := []string{"www.example.com. 60 IN A 192.0.2.10", "www.example.com. 60 IN AAAA 2001:db8::10"}
+	queries := make(chan *http.Request, 10)
+	ids := make(chan uint16, 10)
+	doh := serveDoH(t, queries, ids, records...)
+	dot := serveDoT(t, doh.TLS.Certificates, records...)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(doh.Certificate())
+	trusted := &tls.Config{RootCAs: roots}
+
+	t.Run("DoH queries are POSTed by default with a zero message ID.", func(t *testing.T) {
+		an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{doh.URL + "/dns-query"}, Timeout: time.Second, TLSConfig: trusted}}
+		msg, err := an.initDNSMsg("www.example.com", dns.TypeA)
+		assert.NoError(t, err)
+		assert.Len(t, msg.Answer, 1)
+		assert.NotZero(t, msg.Id)
+
+		req := <-queries
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "/dns-query", req.URL.Path)
+		assert.Equal(t, dnsMessageMedia, req.Header.Get("Accept"))
+		assert.Equal(t, uint16(0), <-ids)
+	})
+
+	t.Run("DoH queries can be sent with GET.", func(t *testing.T) {
+		resolver, err := NewResolver([]string{doh.URL + "/dns-query"}, nil, ResolverOptions{Timeout: time.Second, DoHMethod: "get"})
+		assert.NoError(t, err)
+		resolver.(*UpstreamResolver).TLSConfig = trusted
+		an := DNSAnalyser{Resolver: resolver}
+		ips, err := an.IPLookup("www.example.com")
+		assert.NoError(t, err)
+		assert.Len(t, ips, 2)
+
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.MethodGet, (<-queries).Method)
+			<-ids
+		}
+	})
+
+	t.Run("DoT queries are verified against the host or the name given.", func(t *testing.T) {
+		for _, server := range []string{"tls://" + dot, "tls://" + dot + "#example.com"} {
+			an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{server}, Timeout: time.Second, TLSConfig: trusted}}
+			ips, err := an.IPLookup("www.example.com")
+			assert.NoError(t, err, server)
+			assert.Len(t, ips, 2, server)
+		}
+	})
+
+	t.Run("Servers with untrusted certificates fail.", func(t *testing.T) {
+		an := DNSAnalyser{Resolver: &UpstreamResolver{Servers: []string{doh.URL, "tls://" + dot}, Timeout: time.Second}}
+		_, err := an.initDNSMsg("www.example.com", dns.TypeA)
+		assert.Error(t, err)
+		assert.ErrorContains(t, err, "certificate")
+	})
+
+	t.Run("Transports are selected per resolver.", func(t *testing.T) {
+		plain := serveRecords(t, "app.corp.example. 60 IN A 10.0.0.5")
+		resolver, err := NewResolver([]string{"tls://" + dot}, []string{"corp.example@" + plain}, ResolverOptions{Timeout: time.Second})
+		assert.NoError(t, err)
+		resolver.(*ZoneResolver).Default.(*UpstreamResolver).TLSConfig = trusted
+		an := DNSAnalyser{Resolver: resolver}
+
+		ips, err := an.IPLookup("app.corp.example")
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.5", ips[0].String())
+		ips, err = an.IPLookup("www.example.com")
+		assert.NoError(t, err)
+		assert.Len(t, ips, 2)
+	})
+
+	t.Run("Malformed encrypted resolvers are rejected.", func(t *testing.T) {
+		for _, server := range []string{"https://", "http://dns.example/dns-query", "tls://", "tls://192.0.2.1:99999"} {
+			_, err := NewResolver([]string{server}, nil, ResolverOptions{})
+			assert.Error(t, err, server)
+		}
+		_, err := NewResolver([]string{"https://dns.example/dns-query"}, nil, ResolverOptions{DoHMethod: "PUT"})
+		assert.ErrorContains(t, err, "GET or POST")
+	})
+}
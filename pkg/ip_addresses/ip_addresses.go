@@ -3,6 +3,13 @@ package ip_addresses
 import (
 	"net"
 	"orbit/models"
+	"strings"
+)
+
+// Suffixes of the reverse mapping trees for IPv4 (RFC 1035) and IPv6 (RFC 3596).
+const (
+	ipv4ReverseSuffix = "in-addr.arpa"
+	ipv6ReverseSuffix = "ip6.arpa"
 )
 
 type IPAddresses struct{}
@@ -12,38 +19,53 @@ func (ipa *IPAddresses) IPAddressesFromFile(path string) {}
 // IPAddressesFromZones extract IP addresses from zone file data.
 func (ipa *IPAddresses) IPAddressesFromZones(zone models.ZoneFile) *models.IPCollection {
 	var results models.IPCollection
+	var ips []net.IP
 	for _, rec := range zone.Records {
-		ip := net.ParseIP(rec.Content)
-		exists := func(ips []net.IP, ip net.IP) bool {
-			for i := range ips {
-				if ips[i].String() == ip.String() {
-					return true
-				}
-			}
-			return false
-		}
-		if ipa.IsIPv4(ip) && !exists(results.IPv4, ip) {
-			results.IPv4 = append(results.IPv4, ip)
-		} else if ipa.IsIPv6(ip) && !exists(results.IPv4, ip) {
-			results.IPv6 = append(results.IPv6, ip)
+		if ip := net.ParseIP(rec.Content); ip != nil {
+			ips = append(ips, ip)
 		}
 	}
+	ipa.AddManyIPAddresses(ips, &results)
 	return &results
 }
 
 // AddManyIPStrAddresses receives an IP address and validates if IPv4 or 6 and assigns to ASMAssessment.IPAddresses
 func (ipa *IPAddresses) AddManyIPStrAddresses(ips []string, ipc *models.IPCollection) {
+	parsed := make([]net.IP, 0, len(ips))
 	for i := range ips {
 		if ip := net.ParseIP(ips[i]); ip != nil {
-			ipa.CheckAddIPtoAddresses(ip, ipc)
+			parsed = append(parsed, ip)
 		}
 	}
+	ipa.AddManyIPAddresses(parsed, ipc)
 }
 
-// AddManyIPAddresses receives an IP address and validates if IPv4 or 6 and assigns to ASMAssessment.IPAddresses
+// AddManyIPAddresses receives an IP address and validates if IPv4 or 6 and assigns to ASMAssessment.IPAddresses.
+// Addresses which already exist are skipped using a set rather than searching the collection for each one.
 func (ipa *IPAddresses) AddManyIPAddresses(ips []net.IP, ipc *models.IPCollection) {
+	ipa.AddNewIPAddresses(ips, ipa.AddressSet(ipc), ipc)
+}
+
+// AddressSet returns the addresses of a collection as a set for AddNewIPAddresses.
+func (ipa *IPAddresses) AddressSet(ipc *models.IPCollection) map[string]bool {
+	existing := make(map[string]bool, len(ipc.IPv4)+len(ipc.IPv6))
+	for _, collection := range [][]net.IP{ipc.IPv4, ipc.IPv6} {
+		for i := range collection {
+			existing[collection[i].String()] = true
+		}
+	}
+	return existing
+}
+
+// AddNewIPAddresses adds the addresses which are not in the set of those already in the collection and
+// adds them to the set, so callers adding many batches keep one set rather than rebuilding it each time.
+func (ipa *IPAddresses) AddNewIPAddresses(ips []net.IP, existing map[string]bool, ipc *models.IPCollection) {
 	for i := range ips {
-		ipa.CheckAddIPtoAddresses(ips[i], ipc)
+		if ips[i] == nil || existing[ips[i].String()] {
+			continue
+		}
+		existing[ips[i].String()] = true
+		ipa.addIPInternal(ips[i], ipc)
 	}
 }
 
@@ -93,3 +115,56 @@ func (ipa *IPAddresses) IsIPv4(ip net.IP) bool {
 func (ipa *IPAddresses) IsIPv6(ip net.IP) bool {
 	return ip != nil && ip.To4() == nil && ip.To16() != nil
 }
+
+// IsReverseZone returns true if a zone origin is within the in-addr.arpa or ip6.arpa trees.
+func (ipa *IPAddresses) IsReverseZone(origin string) bool {
+	name := strings.ToLower(strings.TrimSuffix(origin, "."))
+	return name == ipv4ReverseSuffix || name == ipv6ReverseSuffix ||
+		strings.HasSuffix(name, "."+ipv4ReverseSuffix) || strings.HasSuffix(name, "."+ipv6ReverseSuffix)
+}
+
+// ReverseNameToIP converts the owner name of a PTR record, i.e., '10.2.0.192.in-addr.arpa', back into
+// the IP address it maps. Names which do not describe a full address return nil. RFC 2317 classless
+// delegation labels such as '0/25' or '0-127' are skipped.
+func (ipa *IPAddresses) ReverseNameToIP(name string) net.IP {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if labels, ok := strings.CutSuffix(name, "."+ipv4ReverseSuffix); ok {
+		return reverseIPv4(strings.Split(labels, "."))
+	}
+	if labels, ok := strings.CutSuffix(name, "."+ipv6ReverseSuffix); ok {
+		return reverseIPv6(strings.Split(labels, "."))
+	}
+	return nil
+}
+
+func reverseIPv4(labels []string) net.IP {
+	var octets []string
+	for i, label := range labels {
+		if i > 0 && strings.ContainsAny(label, "/-") {
+			continue
+		}
+		octets = append([]string{label}, octets...)
+	}
+	if len(octets) != net.IPv4len {
+		return nil
+	}
+	// ParseIP rejects octets which are out of range or have leading zeros.
+	return net.ParseIP(strings.Join(octets, ".")).To4()
+}
+
+func reverseIPv6(nibbles []string) net.IP {
+	if len(nibbles) != 2*net.IPv6len {
+		return nil
+	}
+	var addr strings.Builder
+	for i := len(nibbles) - 1; i >= 0; i-- {
+		if len(nibbles[i]) != 1 {
+			return nil
+		}
+		addr.WriteString(nibbles[i])
+		if i > 0 && i%4 == 0 {
+			addr.WriteByte(':')
+		}
+	}
+	return net.ParseIP(addr.String())
+}
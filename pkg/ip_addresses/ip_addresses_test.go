@@ -0,0 +1,50 @@
+package ip_addresses
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"orbit/models"
+	"testing"
+)
+
+func TestReverseNameToIP(t *testing.T) {
+	ipa := IPAddresses{}
+
+	t.Run("Reverse zones are recognised.", func(t *testing.T) {
+		assert.True(t, ipa.IsReverseZone("2.0.192.in-addr.arpa."))
+		assert.True(t, ipa.IsReverseZone("8.B.D.0.1.0.0.2.ip6.arpa"))
+		assert.False(t, ipa.IsReverseZone("in-addr.arpa.example.com"))
+	})
+
+	t.Run("IPv4 and IPv6 PTR names map back to addresses.", func(t *testing.T) {
+		assert.Equal(t, "192.0.2.10", ipa.ReverseNameToIP("10.2.0.192.in-addr.arpa.").String())
+		assert.Equal(t, "2001:db8::567:89ab", ipa.ReverseNameToIP(
+			"b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa").String())
+	})
+
+	t.Run("RFC 2317 classless delegation labels are skipped.", func(t *testing.T) {
+		assert.Equal(t, "192.0.2.1", ipa.ReverseNameToIP("1.0/25.2.0.192.in-addr.arpa").String())
+		assert.Equal(t, "192.0.2.129", ipa.ReverseNameToIP("129.128-255.2.0.192.in-addr.arpa").String())
+	})
+
+	t.Run("Partial and malformed names are not addresses.", func(t *testing.T) {
+		assert.Nil(t, ipa.ReverseNameToIP("2.0.192.in-addr.arpa"))
+		assert.Nil(t, ipa.ReverseNameToIP("256.2.0.192.in-addr.arpa"))
+		assert.Nil(t, ipa.ReverseNameToIP("8.b.d.0.1.0.0.2.ip6.arpa"))
+		assert.Nil(t, ipa.ReverseNameToIP("www.example.com"))
+	})
+}
+
+func TestAddNewIPAddresses(t *testing.T) {
+	ipa := IPAddresses{}
+	ipc := models.IPCollection{IPv4: []net.IP{net.ParseIP("192.0.2.10")}}
+	known := ipa.AddressSet(&ipc)
+
+	t.Run("Batches sharing a set add each address once.", func(t *testing.T) {
+		ipa.AddNewIPAddresses([]net.IP{net.ParseIP("192.0.2.10"), net.ParseIP("192.0.2.11")}, known, &ipc)
+		ipa.AddNewIPAddresses([]net.IP{net.ParseIP("192.0.2.11"), net.ParseIP("2001:db8::1"), nil}, known, &ipc)
+		assert.Len(t, ipc.IPv4, 2)
+		assert.Len(t, ipc.IPv6, 1)
+		assert.True(t, known["2001:db8::1"])
+	})
+}
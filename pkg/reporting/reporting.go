@@ -7,22 +7,18 @@ import (
 
 type Reporting struct{}
 
-// AandAAARecords prints A and AAA records to the terminal grouped by zone.
+// AandAAARecords prints A and AAAA records to the terminal grouped by zone.
 func (rep *Reporting) AandAAARecords(zone *models.ZoneFile) []string {
-	return rep.sortRecords(zone, []string{"A", "AAA"})
+	return rep.sortRecords(zone, []string{"A", "AAAA"})
 }
 
-// CNAMERecords prints CNAME records to the terminal grouped by zone.
+// CNAMERecords prints CNAME and provider ALIAS records to the terminal grouped by zone.
 func (rep *Reporting) CNAMERecords(zone *models.ZoneFile) []map[string]string {
 	var results []map[string]string
 	for _, rec := range zone.Records {
-		if rec.Type == "CNAME" {
+		if rec.Type == "CNAME" || rec.Type == "ALIAS" {
 			innerMap := make(map[string]string)
-			con := rec.Content
-			if strings.HasSuffix(con, ".") {
-				con = strings.TrimSuffix(con, ".")
-			}
-			innerMap[rec.Name+"."+zone.Origin] = con
+			innerMap[rec.Name] = rec.Target
 			results = append(results, innerMap)
 		}
 	}
@@ -32,14 +28,13 @@ func (rep *Reporting) CNAMERecords(zone *models.ZoneFile) []map[string]string {
 // GetFQDNs prints FQDNs by resolving A/AAA/CNAME values and IP addresses.
 func (rep *Reporting) GetFQDNs(zone *models.ZoneFile) []string {
 	var results []string
-	origin := strings.TrimSuffix(zone.Origin, ".")
+	seen := make(map[string]bool)
 	for _, rec := range zone.Records {
-		if rec.Type == "A" || rec.Type == "AAA" || rec.Type == "CNAME" {
-			fqdn := rec.Name + "." + origin
-			if rec.Name == "@" || rec.Name == "*" {
-				fqdn = origin
-			}
-			if !rep.SliceContainsString(results, fqdn) {
+		if rec.Type == "A" || rec.Type == "AAAA" || rec.Type == "CNAME" || rec.Type == "ALIAS" {
+			// Wildcards are reported as the name they are defined under.
+			fqdn := strings.TrimPrefix(rec.Name, "*.")
+			if !seen[fqdn] {
+				seen[fqdn] = true
 				results = append(results, fqdn)
 			}
 		}
@@ -47,6 +42,27 @@ func (rep *Reporting) GetFQDNs(zone *models.ZoneFile) []string {
 	return results
 }
 
+// RecordTargets returns the hostnames referenced by the typed data of a record.
+func (rep *Reporting) RecordTargets(rec models.DNSRecord) []string {
+	switch {
+	case rec.Target != "":
+		return []string{rec.Target}
+	case rec.MX != nil:
+		return []string{rec.MX.Exchange}
+	case rec.SRV != nil:
+		return []string{rec.SRV.Target}
+	case rec.SVCB != nil:
+		// A target of '.' refers to the owner name itself.
+		if rec.SVCB.Target == "." {
+			return []string{rec.Name}
+		}
+		return []string{rec.SVCB.Target}
+	case rec.SOA != nil:
+		return []string{rec.SOA.MName}
+	}
+	return nil
+}
+
 // AddURLToAsmDomainsDupSafe checks if the domains list already contains a URL and adds it if not.
 func (rep *Reporting) AddURLToAsmDomainsDupSafe(url string, asm *models.ASMAssessment) {
 	if !rep.SliceContainsString(asm.Domains, url) {
@@ -54,6 +70,32 @@ func (rep *Reporting) AddURLToAsmDomainsDupSafe(url string, asm *models.ASMAsses
 	}
 }
 
+// AddManyURLsToAsmDomains adds the URLs which the domains list does not already contain, using a set
+// rather than searching the list for each one.
+func (rep *Reporting) AddManyURLsToAsmDomains(urls []string, asm *models.ASMAssessment) {
+	rep.AddNewURLsToAsmDomains(urls, rep.DomainSet(asm), asm)
+}
+
+// DomainSet returns the domains list as a set for AddNewURLsToAsmDomains.
+func (rep *Reporting) DomainSet(asm *models.ASMAssessment) map[string]bool {
+	existing := make(map[string]bool, len(asm.Domains))
+	for _, domain := range asm.Domains {
+		existing[domain] = true
+	}
+	return existing
+}
+
+// AddNewURLsToAsmDomains adds the URLs which are not in the set of domains already listed and adds them
+// to the set, so callers adding many batches keep one set rather than rebuilding it each time.
+func (rep *Reporting) AddNewURLsToAsmDomains(urls []string, existing map[string]bool, asm *models.ASMAssessment) {
+	for _, url := range urls {
+		if !existing[url] {
+			existing[url] = true
+			asm.Domains = append(asm.Domains, url)
+		}
+	}
+}
+
 // AddURLToUntrackedDomainsDupSafe checks if the domains list already contains a URL and adds it if not.
 func (rep *Reporting) AddURLToUntrackedDomainsDupSafe(url string, ips []string, asm *models.ASMAssessment) {
 	for _, domain := range asm.UntrackedDomains {
@@ -66,6 +108,28 @@ func (rep *Reporting) AddURLToUntrackedDomainsDupSafe(url string, ips []string,
 	}
 }
 
+// MergeZoneRecords adds the records of a zone to the matching zone of the assessment, skipping records
+// which are already present. Zones with a new origin are added as they are.
+func (rep *Reporting) MergeZoneRecords(zone models.ZoneFile, asm *models.ASMAssessment) {
+	for i := range asm.Zones {
+		if asm.Zones[i].Origin != zone.Origin {
+			continue
+		}
+		existing := make(map[string]bool, len(asm.Zones[i].Records))
+		for _, rec := range asm.Zones[i].Records {
+			existing[rec.Name+" "+rec.Type+" "+rec.Content] = true
+		}
+		for _, rec := range zone.Records {
+			if key := rec.Name + " " + rec.Type + " " + rec.Content; !existing[key] {
+				existing[key] = true
+				asm.Zones[i].Records = append(asm.Zones[i].Records, rec)
+			}
+		}
+		return
+	}
+	asm.Zones = append(asm.Zones, zone)
+}
+
 // AddMissingDNSSec tracks domains which do not have DNSSEC enabled.
 func (rep *Reporting) AddMissingDNSSec(domain string, asm *models.ASMAssessment) {
 	if !rep.SliceContainsString(asm.MissingDNSSEC, domain) {
@@ -73,6 +137,32 @@ func (rep *Reporting) AddMissingDNSSec(domain string, asm *models.ASMAssessment)
 	}
 }
 
+// AddReverseName tracks a hostname which a PTR record maps an IP address to.
+func (rep *Reporting) AddReverseName(ip, name string, asm *models.ASMAssessment) {
+	if asm.ReverseNames == nil {
+		asm.ReverseNames = make(map[string][]string)
+	}
+	if !rep.SliceContainsString(asm.ReverseNames[ip], name) {
+		asm.ReverseNames[ip] = append(asm.ReverseNames[ip], name)
+	}
+}
+
+// SetLookupStatus records the outcome of the address lookup of a domain.
+func (rep *Reporting) SetLookupStatus(domain, status string, asm *models.ASMAssessment) {
+	if asm.LookupStatus == nil {
+		asm.LookupStatus = make(map[string]string)
+	}
+	asm.LookupStatus[domain] = status
+}
+
+// SetCNAMEChain records the chain of CNAMEs a hostname resolves through.
+func (rep *Reporting) SetCNAMEChain(chain models.CNAMEChain, asm *models.ASMAssessment) {
+	if asm.CNAMEChains == nil {
+		asm.CNAMEChains = make(map[string]models.CNAMEChain)
+	}
+	asm.CNAMEChains[chain.Hostname] = chain
+}
+
 // SliceContainsString checks if a []string SliceContainsString a substring.
 func (rep *Reporting) SliceContainsString(items []string, str string) bool {
 	for i := range items {
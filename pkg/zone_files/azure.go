@@ -0,0 +1,218 @@
+package zone_files
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"orbit/models"
+	"strconv"
+	"strings"
+)
+
+// azureRecordSet is an element of the output of 'az network dns record-set list'. Older CLI versions
+// use different capitalisation for the record arrays, which encoding/json matches case-insensitively.
+type azureRecordSet struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Fqdn           string `json:"fqdn"`
+	Type           string `json:"type"`
+	TTL            int    `json:"ttl"`
+	TargetResource *struct {
+		ID string `json:"id"`
+	} `json:"targetResource"`
+	ARecords []struct {
+		IPv4Address string `json:"ipv4Address"`
+	} `json:"aRecords"`
+	AAAARecords []struct {
+		IPv6Address string `json:"ipv6Address"`
+	} `json:"aaaaRecords"`
+	CNAMERecord *struct {
+		Cname string `json:"cname"`
+	} `json:"cnameRecord"`
+	MXRecords []struct {
+		Exchange   string `json:"exchange"`
+		Preference int    `json:"preference"`
+	} `json:"mxRecords"`
+	NSRecords []struct {
+		Nsdname string `json:"nsdname"`
+	} `json:"nsRecords"`
+	PTRRecords []struct {
+		Ptrdname string `json:"ptrdname"`
+	} `json:"ptrRecords"`
+	SRVRecords []struct {
+		Priority int    `json:"priority"`
+		Weight   int    `json:"weight"`
+		Port     int    `json:"port"`
+		Target   string `json:"target"`
+	} `json:"srvRecords"`
+	TXTRecords []struct {
+		Value []string `json:"value"`
+	} `json:"txtRecords"`
+	CAARecords []struct {
+		Flags int    `json:"flags"`
+		Tag   string `json:"tag"`
+		Value string `json:"value"`
+	} `json:"caaRecords"`
+	SOARecord *struct {
+		Host         string `json:"host"`
+		Email        string `json:"email"`
+		SerialNumber uint32 `json:"serialNumber"`
+		RefreshTime  int    `json:"refreshTime"`
+		RetryTime    int    `json:"retryTime"`
+		ExpireTime   int    `json:"expireTime"`
+		MinimumTTL   int    `json:"minimumTtl"`
+	} `json:"soaRecord"`
+}
+
+// azureAliasServices maps the resource provider of an alias target to the service behind it and
+// the suffix of the hostname it publishes, if any.
+var azureAliasServices = []struct {
+	provider string
+	service  string
+	suffix   string
+}{
+	{"microsoft.network/trafficmanagerprofiles", "traffic-manager", "trafficmanager.net"},
+	{"microsoft.network/frontdoors", "front-door", "azurefd.net"},
+	{"microsoft.cdn/profiles", "cdn", "azureedge.net"},
+	{"microsoft.network/publicipaddresses", "public-ip", ""},
+}
+
+// parseAzureData converts an Azure DNS record set listing into a zone. The origin is taken from the
+// zone named in the record set IDs, falling back to the origin provided. In lenient mode record sets
+// which fail to parse are skipped.
+func parseAzureData(data []byte, origin string, lenient bool) (models.ZoneFile, []ParseError, error) {
+	var sets []azureRecordSet
+	if err := json.Unmarshal(data, &sets); err != nil {
+		return models.ZoneFile{}, nil, err
+	}
+	zf := models.ZoneFile{Origin: strings.TrimSuffix(origin, ".")}
+	for _, rs := range sets {
+		if zone := azureZoneFromID(rs.ID); zone != "" {
+			zf.Origin = zone
+			break
+		}
+	}
+	if zf.Origin == "" {
+		return models.ZoneFile{}, nil, errors.New("unable to determine the zone origin")
+	}
+
+	bad := recordSkipper{lenient: lenient}
+	for _, rs := range sets {
+		rtype := strings.ToUpper(rs.Type[strings.LastIndex(rs.Type, "/")+1:])
+		name := trimDot(rs.Fqdn)
+		if name == "" {
+			name = zf.Origin
+			if rs.Name != "@" {
+				name = rs.Name + "." + zf.Origin
+			}
+		}
+
+		if rs.TargetResource != nil && rs.TargetResource.ID != "" {
+			zf.Records = append(zf.Records, azureAliasRecord(rs, name, rtype))
+			continue
+		}
+		var recs []models.DNSRecord
+		var err error
+		for _, rdata := range azureRecordData(rs) {
+			var parsed dns.RR
+			if parsed, err = parseRR(name, rs.TTL, "IN", rtype, rdata, "."); err != nil {
+				break
+			}
+			recs = append(recs, RecordFromRR(parsed))
+		}
+		if err != nil {
+			if err := bad.skip(name+" "+rtype, err); err != nil {
+				return models.ZoneFile{}, nil, err
+			}
+			continue
+		}
+		zf.Records = append(zf.Records, recs...)
+	}
+	return zf, bad.skipped, nil
+}
+
+// azureRecordData returns the RDATA of each record in a record set in presentation format.
+func azureRecordData(rs azureRecordSet) []string {
+	var res []string
+	for _, r := range rs.ARecords {
+		res = append(res, r.IPv4Address)
+	}
+	for _, r := range rs.AAAARecords {
+		res = append(res, r.IPv6Address)
+	}
+	if rs.CNAMERecord != nil {
+		res = append(res, rs.CNAMERecord.Cname)
+	}
+	for _, r := range rs.MXRecords {
+		res = append(res, strconv.Itoa(r.Preference)+" "+r.Exchange)
+	}
+	for _, r := range rs.NSRecords {
+		res = append(res, r.Nsdname)
+	}
+	for _, r := range rs.PTRRecords {
+		res = append(res, r.Ptrdname)
+	}
+	for _, r := range rs.SRVRecords {
+		res = append(res, fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target))
+	}
+	for _, r := range rs.TXTRecords {
+		segments := make([]string, len(r.Value))
+		for i := range r.Value {
+			segments[i] = quoteTXT(r.Value[i])
+		}
+		res = append(res, strings.Join(segments, " "))
+	}
+	for _, r := range rs.CAARecords {
+		res = append(res, fmt.Sprintf("%d %s %s", r.Flags, r.Tag, quoteTXT(r.Value)))
+	}
+	if soa := rs.SOARecord; soa != nil {
+		res = append(res, fmt.Sprintf("%s %s %d %d %d %d %d", soa.Host, soa.Email, soa.SerialNumber,
+			soa.RefreshTime, soa.RetryTime, soa.ExpireTime, soa.MinimumTTL))
+	}
+	return res
+}
+
+// azureAliasRecord represents an alias record set pointing at an Azure resource. Traffic Manager,
+// Front Door and CDN targets are resolved to the hostname the resource publishes.
+func azureAliasRecord(rs azureRecordSet, name, rtype string) models.DNSRecord {
+	id := rs.TargetResource.ID
+	attrs := map[string]string{
+		"alias-type":        rtype,
+		"alias-resource-id": id,
+		"alias-service":     "azure",
+	}
+	var target string
+	lower := strings.ToLower(id)
+	for _, as := range azureAliasServices {
+		if !strings.Contains(lower, "/providers/"+as.provider+"/") {
+			continue
+		}
+		attrs["alias-service"] = as.service
+		if as.suffix != "" {
+			target = strings.ToLower(id[strings.LastIndex(id, "/")+1:]) + "." + as.suffix
+		}
+		break
+	}
+	return models.DNSRecord{
+		Type:       "ALIAS",
+		Class:      "IN",
+		Name:       name,
+		Content:    id,
+		TTL:        rs.TTL,
+		Target:     target,
+		Attributes: attrs,
+	}
+}
+
+// azureZoneFromID extracts the zone name from a record set resource ID such as
+// '/subscriptions/.../providers/Microsoft.Network/dnszones/example.com/A/www'.
+func azureZoneFromID(id string) string {
+	parts := strings.Split(id, "/")
+	for i := range parts {
+		if strings.EqualFold(parts[i], "dnszones") && i+1 < len(parts) {
+			return trimDot(parts[i+1])
+		}
+	}
+	return ""
+}
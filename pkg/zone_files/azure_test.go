@@ -0,0 +1,45 @@
+package zone_files
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var azureSample = []byte(`[
+  {"id": "/subscriptions/0000/resourceGroups/dns/providers/Microsoft.Network/dnszones/example.com/A/www",
+   "name": "www", "fqdn": "www.example.com.", "type": "Microsoft.Network/dnszones/A", "ttl": 3600,
+   "aRecords": [{"ipv4Address": "192.0.2.1"}, {"ipv4Address": "192.0.2.2"}]},
+  {"id": "/subscriptions/0000/resourceGroups/dns/providers/Microsoft.Network/dnszones/example.com/MX/@",
+   "name": "@", "type": "Microsoft.Network/dnszones/MX", "ttl": 3600,
+   "mxRecords": [{"exchange": "example-com.mail.protection.outlook.com", "preference": 0}]},
+  {"id": "/subscriptions/0000/resourceGroups/dns/providers/Microsoft.Network/dnszones/example.com/TXT/@",
+   "name": "@", "fqdn": "example.com.", "type": "Microsoft.Network/dnszones/TXT", "ttl": 300,
+   "TXTRecords": [{"value": ["v=spf1 include:spf.protection.outlook.com -all"]}]},
+  {"id": "/subscriptions/0000/resourceGroups/dns/providers/Microsoft.Network/dnszones/example.com/CNAME/app",
+   "name": "app", "fqdn": "app.example.com.", "type": "Microsoft.Network/dnszones/CNAME", "ttl": 60,
+   "targetResource": {"id": "/subscriptions/0000/resourceGroups/web/providers/Microsoft.Network/trafficManagerProfiles/example-app"}}
+]`)
+
+func TestParseAzureData(t *testing.T) {
+	zf, _, err := parseAzureData(azureSample, "fallback", false)
+	assert.NoError(t, err)
+
+	t.Run("The origin is taken from the record set IDs.", func(t *testing.T) {
+		assert.Equal(t, "example.com", zf.Origin)
+		assert.Len(t, zf.Records, 5)
+	})
+
+	t.Run("Record arrays are converted to typed records.", func(t *testing.T) {
+		assert.Equal(t, "192.0.2.2", zf.Records[1].Content)
+		assert.Equal(t, "example.com", zf.Records[2].Name)
+		assert.Equal(t, "example-com.mail.protection.outlook.com", zf.Records[2].MX.Exchange)
+		assert.Equal(t, []string{"v=spf1 include:spf.protection.outlook.com -all"}, zf.Records[3].TXT)
+	})
+
+	t.Run("Traffic Manager alias record sets resolve to the profile hostname.", func(t *testing.T) {
+		alias := zf.Records[4]
+		assert.Equal(t, "ALIAS", alias.Type)
+		assert.Equal(t, "example-app.trafficmanager.net", alias.Target)
+		assert.Equal(t, "traffic-manager", alias.Attributes["alias-service"])
+	})
+}
@@ -0,0 +1,120 @@
+package zone_files
+
+import (
+	"orbit/models"
+	"sort"
+	"strings"
+)
+
+// DiffZones compares two snapshots of zones and returns the added, removed and modified records of
+// each origin which changed. Records are compared as record sets of the same name, class and type.
+// A set which swapped its only value, or kept its values but changed TTL, is reported as modified.
+func (dz *DNSZones) DiffZones(old, new []models.ZoneFile) []models.ZoneDiff {
+	oldSets, newSets := recordSetsByOrigin(old), recordSetsByOrigin(new)
+	var origins []string
+	for origin := range oldSets {
+		origins = append(origins, origin)
+	}
+	for origin := range newSets {
+		if _, ok := oldSets[origin]; !ok {
+			origins = append(origins, origin)
+		}
+	}
+	sort.Strings(origins)
+
+	var results []models.ZoneDiff
+	for _, origin := range origins {
+		diff := diffRecordSets(oldSets[origin], newSets[origin])
+		if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Modified) == 0 {
+			continue
+		}
+		diff.Origin = origin
+		results = append(results, diff)
+	}
+	return results
+}
+
+// recordSetsByOrigin groups the records of each origin into sets keyed by name, class and type,
+// merging zones which share an origin.
+func recordSetsByOrigin(zones []models.ZoneFile) map[string]map[string][]models.DNSRecord {
+	results := make(map[string]map[string][]models.DNSRecord)
+	for _, zf := range zones {
+		origin := strings.ToLower(zf.Origin)
+		if results[origin] == nil {
+			results[origin] = make(map[string][]models.DNSRecord)
+		}
+		for _, rec := range zf.Records {
+			key := strings.ToLower(rec.Name) + " " + rec.Class + " " + rec.Type
+			results[origin][key] = append(results[origin][key], rec)
+		}
+	}
+	return results
+}
+
+func diffRecordSets(old, new map[string][]models.DNSRecord) models.ZoneDiff {
+	var keys []string
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var diff models.ZoneDiff
+	for _, key := range keys {
+		removed, added, changed := diffRecordSet(old[key], new[key])
+		if len(removed) == 1 && len(added) == 1 {
+			changed = append(changed, models.RecordChange{Old: removed[0], New: added[0]})
+			removed, added = nil, nil
+		}
+		diff.Removed = append(diff.Removed, removed...)
+		diff.Added = append(diff.Added, added...)
+		diff.Modified = append(diff.Modified, changed...)
+	}
+	return diff
+}
+
+// diffRecordSet compares the values of a record set, returning the values only in the old set,
+// those only in the new set and those whose TTL changed. Duplicates of a value are compared once.
+func diffRecordSet(old, new []models.DNSRecord) ([]models.DNSRecord, []models.DNSRecord, []models.RecordChange) {
+	oldByContent := recordsByContent(old)
+	newByContent := recordsByContent(new)
+
+	var removed, added []models.DNSRecord
+	var changed []models.RecordChange
+	seen := make(map[string]bool)
+	for _, rec := range old {
+		if _, ok := newByContent[rec.Content]; !ok && !seen[rec.Content] {
+			removed = append(removed, rec)
+		}
+		seen[rec.Content] = true
+	}
+	seen = make(map[string]bool)
+	for _, rec := range new {
+		if seen[rec.Content] {
+			continue
+		}
+		seen[rec.Content] = true
+		prev, ok := oldByContent[rec.Content]
+		switch {
+		case !ok:
+			added = append(added, rec)
+		case prev.TTL != rec.TTL:
+			changed = append(changed, models.RecordChange{Old: prev, New: rec})
+		}
+	}
+	return removed, added, changed
+}
+
+func recordsByContent(records []models.DNSRecord) map[string]models.DNSRecord {
+	results := make(map[string]models.DNSRecord, len(records))
+	for _, rec := range records {
+		if _, ok := results[rec.Content]; !ok {
+			results[rec.Content] = rec
+		}
+	}
+	return results
+}
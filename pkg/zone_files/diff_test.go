@@ -0,0 +1,54 @@
+package zone_files
+
+import (
+	"github.com/stretchr/testify/assert"
+	"orbit/models"
+	"strings"
+	"testing"
+)
+
+func TestDiffZones(t *testing.T) {
+	parse := func(data string) []models.ZoneFile {
+		zones, err := parseZoneFileData(strings.Split(data, "\n"), "example.com")
+		assert.NoError(t, err)
+		return zones
+	}
+	old := parse("$TTL 60\nwww A 192.0.2.1\nold A 192.0.2.2\napi A 192.0.2.3\nmx MX 10 mail\nmx MX 20 mail2\nmx MX 30 mail3")
+	dz := DNSZones{}
+
+	t.Run("Identical snapshots have no differences.", func(t *testing.T) {
+		assert.Empty(t, dz.DiffZones(old, parse("$TTL 60\nmx MX 30 mail3\nmx MX 20 mail2\nmx MX 10 mail\napi A 192.0.2.3\nold A 192.0.2.2\nwww A 192.0.2.1")))
+	})
+
+	t.Run("Records are added, removed or modified within their record set.", func(t *testing.T) {
+		diffs := dz.DiffZones(old, parse("$TTL 60\nwww 300 A 192.0.2.1\nnew A 192.0.2.9\napi A 192.0.2.4\nmx MX 10 mail\nmx MX 40 mail4\nmx MX 50 mail5"))
+		assert.Len(t, diffs, 1)
+		assert.Equal(t, "example.com", diffs[0].Origin)
+
+		var added, removed []string
+		for _, rec := range diffs[0].Added {
+			added = append(added, rec.Name+" "+rec.Content)
+		}
+		for _, rec := range diffs[0].Removed {
+			removed = append(removed, rec.Name+" "+rec.Content)
+		}
+		assert.ElementsMatch(t, []string{"new.example.com 192.0.2.9", "mx.example.com 40 mail4.example.com.",
+			"mx.example.com 50 mail5.example.com."}, added)
+		assert.ElementsMatch(t, []string{"old.example.com 192.0.2.2", "mx.example.com 20 mail2.example.com.",
+			"mx.example.com 30 mail3.example.com."}, removed)
+
+		assert.Len(t, diffs[0].Modified, 2)
+		assert.Equal(t, "192.0.2.3", diffs[0].Modified[0].Old.Content)
+		assert.Equal(t, "192.0.2.4", diffs[0].Modified[0].New.Content)
+		assert.Equal(t, 300, diffs[0].Modified[1].New.TTL)
+	})
+
+	t.Run("Zones only in one snapshot are wholly added or removed.", func(t *testing.T) {
+		other, err := parseZoneFileData([]string{"$TTL 60", "@ A 192.0.2.5"}, "example.org")
+		assert.NoError(t, err)
+		diffs := dz.DiffZones(old, append(old, other...))
+		assert.Len(t, diffs, 1)
+		assert.Equal(t, "example.org", diffs[0].Origin)
+		assert.Len(t, diffs[0].Added, 1)
+	})
+}
@@ -0,0 +1,58 @@
+package zone_files
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError describes zone data which could not be parsed. Line is zero when the error applies
+// to a whole file rather than one of its lines.
+type ParseError struct {
+	File string
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+	if e.File != "" {
+		sb.WriteString(e.File + ": ")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&sb, "line %d: ", e.Line)
+	}
+	sb.WriteString(e.Err.Error())
+	if e.Text != "" {
+		fmt.Fprintf(&sb, ": %q", e.Text)
+	}
+	return sb.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// recordSkipper handles record sets of provider exports which fail to parse. In lenient mode they
+// are skipped and noted, otherwise they fail the whole file.
+type recordSkipper struct {
+	lenient bool
+	skipped []ParseError
+}
+
+// skip returns the error which fails the file, or nil when the record set was skipped.
+func (rs *recordSkipper) skip(text string, err error) error {
+	if !rs.lenient {
+		return fmt.Errorf("%s: %w", text, err)
+	}
+	rs.skipped = append(rs.skipped, ParseError{Text: text, Err: err})
+	return nil
+}
+
+// fileError attributes an error to a file unless it already describes where it occurred.
+func fileError(path string, err error) *ParseError {
+	if pe, ok := err.(*ParseError); ok {
+		return pe
+	}
+	return &ParseError{File: path, Err: err}
+}
@@ -0,0 +1,110 @@
+package zone_files
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"orbit/models"
+	"strconv"
+	"strings"
+)
+
+// gcloudRecordSet is an element of the output of 'gcloud dns record-sets list --format=json'.
+type gcloudRecordSet struct {
+	Kind          string   `json:"kind"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	TTL           int      `json:"ttl"`
+	Rrdatas       []string `json:"rrdatas"`
+	RoutingPolicy *struct {
+		Geo *struct {
+			Items []struct {
+				Location string   `json:"location"`
+				Rrdatas  []string `json:"rrdatas"`
+			} `json:"items"`
+		} `json:"geo"`
+		Wrr *struct {
+			Items []struct {
+				Weight  float64  `json:"weight"`
+				Rrdatas []string `json:"rrdatas"`
+			} `json:"items"`
+		} `json:"wrr"`
+	} `json:"routingPolicy"`
+}
+
+// parseGCloudData converts a Google Cloud DNS record set listing into a zone. The origin is taken
+// from the SOA record set, falling back to the origin provided. In lenient mode record sets which fail
+// to parse are skipped.
+func parseGCloudData(data []byte, origin string, lenient bool) (models.ZoneFile, []ParseError, error) {
+	var sets []gcloudRecordSet
+	if err := json.Unmarshal(data, &sets); err != nil {
+		return models.ZoneFile{}, nil, err
+	}
+	zf := models.ZoneFile{Origin: strings.TrimSuffix(origin, ".")}
+	for _, rs := range sets {
+		if rs.Type == "SOA" {
+			zf.Origin = trimDot(rs.Name)
+			break
+		}
+	}
+	if zf.Origin == "" {
+		return models.ZoneFile{}, nil, errors.New("unable to determine the zone origin")
+	}
+
+	bad := recordSkipper{lenient: lenient}
+	for _, rs := range sets {
+		recs, err := gcloudRecords(rs)
+		if err != nil {
+			if err := bad.skip(rs.Name+" "+rs.Type, err); err != nil {
+				return models.ZoneFile{}, nil, err
+			}
+			continue
+		}
+		zf.Records = append(zf.Records, recs...)
+	}
+	return zf, bad.skipped, nil
+}
+
+// gcloudRecords converts the records of a record set, including those of its routing policy.
+func gcloudRecords(rs gcloudRecordSet) ([]models.DNSRecord, error) {
+	var recs []models.DNSRecord
+	add := func(rrdatas []string, attrs map[string]string) error {
+		for _, rdata := range rrdatas {
+			parsed, err := parseRR(rs.Name, rs.TTL, "IN", rs.Type, rdata, ".")
+			if err != nil {
+				return err
+			}
+			rec := RecordFromRR(parsed)
+			rec.Attributes = maps.Clone(attrs)
+			recs = append(recs, rec)
+		}
+		return nil
+	}
+
+	if err := add(rs.Rrdatas, nil); err != nil {
+		return nil, err
+	}
+	if rs.RoutingPolicy == nil {
+		return recs, nil
+	}
+	if geo := rs.RoutingPolicy.Geo; geo != nil {
+		for _, item := range geo.Items {
+			attrs := map[string]string{"routing-policy": "geo", "location": item.Location}
+			if err := add(item.Rrdatas, attrs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if wrr := rs.RoutingPolicy.Wrr; wrr != nil {
+		for _, item := range wrr.Items {
+			attrs := map[string]string{
+				"routing-policy": "weighted",
+				"weight":         strconv.FormatFloat(item.Weight, 'f', -1, 64),
+			}
+			if err := add(item.Rrdatas, attrs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return recs, nil
+}
@@ -0,0 +1,34 @@
+package zone_files
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var gcloudSample = []byte(`[
+  {"kind": "dns#resourceRecordSet", "name": "example.com.", "type": "SOA", "ttl": 21600,
+   "rrdatas": ["ns-cloud-a1.googledomains.com. cloud-dns-hostmaster.google.com. 1 21600 3600 259200 300"]},
+  {"kind": "dns#resourceRecordSet", "name": "www.example.com.", "type": "CNAME", "ttl": 300,
+   "rrdatas": ["ghs.googlehosted.com."]},
+  {"kind": "dns#resourceRecordSet", "name": "geo.example.com.", "type": "A", "ttl": 60,
+   "routingPolicy": {"geo": {"items": [
+     {"location": "europe-west2", "rrdatas": ["192.0.2.1"]},
+     {"location": "us-east1", "rrdatas": ["192.0.2.2"]}]}}}
+]`)
+
+func TestParseGCloudData(t *testing.T) {
+	zf, _, err := parseGCloudData(gcloudSample, "fallback", false)
+	assert.NoError(t, err)
+
+	t.Run("The origin is taken from the SOA record set.", func(t *testing.T) {
+		assert.Equal(t, "example.com", zf.Origin)
+		assert.Len(t, zf.Records, 4)
+		assert.Equal(t, "ghs.googlehosted.com", zf.Records[1].Target)
+	})
+
+	t.Run("Routing policy items become records with their location.", func(t *testing.T) {
+		assert.Equal(t, "192.0.2.2", zf.Records[3].Content)
+		assert.Equal(t, "geo", zf.Records[3].Attributes["routing-policy"])
+		assert.Equal(t, "us-east1", zf.Records[3].Attributes["location"])
+	})
+}
@@ -0,0 +1,226 @@
+package zone_files
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"maps"
+	"orbit/models"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// octoDNSDefaultTTL is the TTL octoDNS applies to records which do not set one.
+const octoDNSDefaultTTL = 3600
+
+// octoDNSFields lists, in RDATA order, the value keys of octoDNS record types with structured values.
+var octoDNSFields = map[string][]string{
+	"MX":    {"preference", "exchange"},
+	"SRV":   {"priority", "weight", "port", "target"},
+	"CAA":   {"flags", "tag", "value"},
+	"SSHFP": {"algorithm", "fingerprint_type", "fingerprint"},
+	"NAPTR": {"order", "preference", "flags", "service", "regexp", "replacement"},
+	"DS":    {"key_tag", "algorithm", "digest_type", "digest"},
+	"TLSA":  {"certificate_usage", "selector", "matching_type", "certificate_association_data"},
+	"LOC": {"lat_degrees", "lat_minutes", "lat_seconds", "lat_direction", "long_degrees", "long_minutes",
+		"long_seconds", "long_direction", "altitude", "size", "precision_horz", "precision_vert"},
+}
+
+// octoDNSQuoted lists the structured value keys which are character strings.
+var octoDNSQuoted = []string{"CAA.value", "NAPTR.flags", "NAPTR.service", "NAPTR.regexp"}
+
+// parseOctoDNSData converts an octoDNS zone config, a YAML document of records keyed by name, into
+// a zone. octoDNS names zone files after the zone so the origin comes from the file name. In lenient
+// mode records which fail to parse are skipped.
+func parseOctoDNSData(data []byte, origin string, lenient bool) (models.ZoneFile, []ParseError, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return models.ZoneFile{}, nil, err
+	}
+	if _, ok := doc["providers"]; ok {
+		return models.ZoneFile{}, nil, errors.New("octoDNS main config rather than a zone config")
+	}
+	zf := models.ZoneFile{Origin: strings.TrimSuffix(origin, ".")}
+	if zf.Origin == "" {
+		return models.ZoneFile{}, nil, errors.New("unable to determine the zone origin")
+	}
+	bad := recordSkipper{lenient: lenient}
+
+	for _, name := range sortedKeys(doc) {
+		fqdn := zf.Origin
+		if name != "" {
+			fqdn = name + "." + zf.Origin
+		}
+		// A name holds either a single record or a list of records of different types.
+		entries, ok := doc[name].([]interface{})
+		if !ok {
+			entries = []interface{}{doc[name]}
+		}
+		for _, entry := range entries {
+			var recs []models.DNSRecord
+			var err error
+			if record, ok := entry.(map[string]interface{}); ok {
+				recs, err = octoDNSRecords(fqdn, zf.Origin, record)
+			} else {
+				err = errors.New("record is not a mapping")
+			}
+			if err != nil {
+				if err := bad.skip(fqdn, err); err != nil {
+					return models.ZoneFile{}, nil, err
+				}
+				continue
+			}
+			zf.Records = append(zf.Records, recs...)
+		}
+	}
+	return zf, bad.skipped, nil
+}
+
+// octoDNSRecords converts one octoDNS record, which may carry a single value or a list of values.
+func octoDNSRecords(name, origin string, record map[string]interface{}) ([]models.DNSRecord, error) {
+	if record["type"] == nil {
+		return nil, errors.New("record has no type")
+	}
+	rtype := strings.ToUpper(fmt.Sprint(record["type"]))
+	ttl := octoDNSDefaultTTL
+	if v, ok := record["ttl"].(int); ok {
+		ttl = v
+	}
+	values, ok := record["values"].([]interface{})
+	if !ok && record["value"] != nil {
+		values = []interface{}{record["value"]}
+	}
+	attrs := octoDNSAttributes(record)
+
+	var results []models.DNSRecord
+	for _, v := range values {
+		if rtype == "ALIAS" {
+			target := trimDot(fmt.Sprint(v))
+			results = append(results, models.DNSRecord{
+				Type:       rtype,
+				Class:      "IN",
+				Name:       name,
+				Content:    target,
+				TTL:        ttl,
+				Target:     target,
+				Attributes: maps.Clone(attrs),
+			})
+			continue
+		}
+		rdata, err := octoDNSRecordData(rtype, v)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseRR(name, ttl, "IN", rtype, rdata, origin)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rtype, err)
+		}
+		rec := RecordFromRR(parsed)
+		rec.Attributes = maps.Clone(attrs)
+		results = append(results, rec)
+	}
+	return results, nil
+}
+
+// octoDNSRecordData returns an octoDNS value as RDATA in presentation format.
+func octoDNSRecordData(rtype string, v interface{}) (string, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if rtype == "SVCB" || rtype == "HTTPS" {
+			return octoDNSSvcbData(value), nil
+		}
+		fields, ok := octoDNSFields[rtype]
+		if !ok {
+			return "", fmt.Errorf("unsupported structured value for %s", rtype)
+		}
+		// Older octoDNS configs use 'priority' and 'value' for MX records.
+		if rtype == "MX" && value["exchange"] == nil {
+			fields = []string{"priority", "value"}
+		}
+		parts := make([]string, len(fields))
+		for i, field := range fields {
+			if value[field] == nil {
+				return "", fmt.Errorf("%s value is missing %s", rtype, field)
+			}
+			parts[i] = fmt.Sprint(value[field])
+			if slices.Contains(octoDNSQuoted, rtype+"."+field) {
+				parts[i] = quoteTXT(parts[i])
+			}
+		}
+		if rtype == "LOC" {
+			// Altitude, size and precision are given in metres.
+			for i := 8; i < len(parts); i++ {
+				parts[i] += "m"
+			}
+		}
+		return strings.Join(parts, " "), nil
+	case nil:
+		return "", fmt.Errorf("empty %s value", rtype)
+	}
+
+	s := fmt.Sprint(v)
+	if rtype == "TXT" || rtype == "SPF" {
+		// octoDNS escapes semicolons in TXT values.
+		return quoteTXT(strings.ReplaceAll(s, `\;`, ";")), nil
+	}
+	return s, nil
+}
+
+// octoDNSSvcbData formats an SVCB or HTTPS value with its service parameters.
+func octoDNSSvcbData(value map[string]interface{}) string {
+	parts := []string{fmt.Sprint(value["svcpriority"]), fmt.Sprint(value["targetname"])}
+	params, _ := value["svcparams"].(map[string]interface{})
+	for _, key := range sortedKeys(params) {
+		switch p := params[key].(type) {
+		case []interface{}:
+			items := make([]string, len(p))
+			for i := range p {
+				items[i] = fmt.Sprint(p[i])
+			}
+			parts = append(parts, key+"="+strings.Join(items, ","))
+		case nil:
+			parts = append(parts, key)
+		default:
+			parts = append(parts, key+"="+fmt.Sprint(p))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// octoDNSAttributes flattens the provider specific 'octodns' settings of a record and notes any
+// dynamic routing rules.
+func octoDNSAttributes(record map[string]interface{}) map[string]string {
+	attrs := make(map[string]string)
+	var flatten func(prefix string, v interface{})
+	flatten = func(prefix string, v interface{}) {
+		if m, ok := v.(map[string]interface{}); ok {
+			for k, inner := range m {
+				flatten(prefix+"."+k, inner)
+			}
+			return
+		}
+		attrs[prefix] = fmt.Sprint(v)
+	}
+	if settings, ok := record["octodns"].(map[string]interface{}); ok {
+		for k, v := range settings {
+			flatten("octodns."+k, v)
+		}
+	}
+	if record["dynamic"] != nil {
+		attrs["routing-policy"] = "dynamic"
+	}
+	if len(attrs) == 0 {
+		return nil
+	}
+	return attrs
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package zone_files
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var octoDNSSample = []byte(`---
+'':
+  - type: A
+    values:
+      - 192.0.2.1
+      - 192.0.2.2
+  - type: MX
+    values:
+      - exchange: mx1.example.com.
+        preference: 10
+  - type: TXT
+    ttl: 300
+    value: v=spf1 include:_spf.example.net ~all\; comment
+_sip._tcp:
+  type: SRV
+  value:
+    port: 5060
+    priority: 10
+    target: sip.example.com.
+    weight: 20
+www:
+  type: CNAME
+  value: example.com.
+  octodns:
+    cloudflare:
+      proxied: true
+`)
+
+func TestParseOctoDNSData(t *testing.T) {
+	zf, _, err := parseOctoDNSData(octoDNSSample, "example.com", false)
+	assert.NoError(t, err)
+
+	t.Run("Records are keyed by name with the apex as ''.", func(t *testing.T) {
+		assert.Equal(t, "example.com", zf.Origin)
+		assert.Len(t, zf.Records, 6)
+		assert.Equal(t, "example.com", zf.Records[0].Name)
+		assert.Equal(t, 3600, zf.Records[0].TTL)
+		assert.Equal(t, "192.0.2.2", zf.Records[1].Content)
+	})
+
+	t.Run("Structured values are converted to typed records.", func(t *testing.T) {
+		assert.Equal(t, "mx1.example.com", zf.Records[2].MX.Exchange)
+		assert.Equal(t, []string{"v=spf1 include:_spf.example.net ~all; comment"}, zf.Records[3].TXT)
+		assert.Equal(t, 300, zf.Records[3].TTL)
+		assert.Equal(t, 5060, zf.Records[4].SRV.Port)
+		assert.Equal(t, "_sip._tcp.example.com", zf.Records[4].Name)
+	})
+
+	t.Run("Provider specific settings are kept as attributes.", func(t *testing.T) {
+		assert.Equal(t, "example.com", zf.Records[5].Target)
+		assert.Equal(t, "true", zf.Records[5].Attributes["octodns.cloudflare.proxied"])
+	})
+
+	t.Run("Config directories are read through GetZoneData.", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "example.com.yaml"), octoDNSSample, 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "production.yaml"), []byte("providers: {}\nzones: {}\n"), 0o644))
+
+		dz := DNSZones{}
+		zones, err := dz.GetZoneData(dir)
+		assert.NoError(t, err)
+		assert.Len(t, zones, 1)
+		assert.Equal(t, "example.com", zones[0].Origin)
+	})
+}
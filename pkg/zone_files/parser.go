@@ -0,0 +1,651 @@
+package zone_files
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"orbit/internal/file_management"
+	"orbit/models"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// zoneEntry is a single logical master file entry with any parenthesised continuation lines joined.
+type zoneEntry struct {
+	line   int
+	text   string // The first line of the entry, used when reporting errors.
+	blank  bool   // The entry started with whitespace so the owner name is omitted.
+	tokens []string
+}
+
+// zoneLexer splits master file lines into entries as described in RFC 1035 section 5.1.
+type zoneLexer struct {
+	depth   int
+	current *zoneEntry
+}
+
+// zoneParser holds the state carried between master file entries and the zones collected so far.
+type zoneParser struct {
+	origin     string
+	explicit   bool // The origin was declared by the file rather than derived from its name.
+	defaultTTL int
+	hasTTL     bool
+	lastOwner  string
+	lastTTL    int
+	lastClass  string
+	includes   []string // Files currently being read, used to detect $INCLUDE cycles.
+	lenient    bool     // Skip entries which fail to parse rather than stopping at the first.
+	skipped    []ParseError
+	zones      []models.ZoneFile
+	index      map[string]int
+	implicit   map[string]bool
+	// emit receives each record as it is parsed instead of the record being kept in zones. Once it
+	// returns an error, stopped holds the error and parsing ends.
+	emit    func(ZoneRecord) error
+	stopped error
+	renamed map[string]string
+}
+
+var ttlUnits = map[rune]int{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+
+// maxGenerated bounds the number of records a single $GENERATE entry can produce.
+const maxGenerated = 65536
+
+// parseZoneFile reads and parses an RFC 1035 master file, following any $INCLUDE directives
+// relative to the including file. In lenient mode entries which fail to parse are skipped and
+// returned alongside the zones instead of failing the whole file.
+func parseZoneFile(path, origin string, lenient bool) ([]models.ZoneFile, []ParseError, error) {
+	zp := newZoneParser(origin)
+	zp.lenient = lenient
+	if err := zp.parseRoot(path); err != nil {
+		return nil, nil, err
+	}
+	return zp.result(), zp.skipped, nil
+}
+
+// streamZoneFile parses an RFC 1035 master file like parseZoneFile but passes each record to fn as
+// soon as it is parsed, so neither the lines nor the records of the file are held in memory. A zone
+// whose origin was derived from the file name takes the owner of its SOA record as the origin for
+// the records which follow it.
+func streamZoneFile(path, origin string, lenient bool, fn func(ZoneRecord) error) ([]ParseError, error) {
+	zp := newZoneParser(origin)
+	zp.lenient = lenient
+	zp.emit = fn
+	zp.renamed = make(map[string]string)
+	if err := zp.parseRoot(path); err != nil {
+		return zp.skipped, err
+	}
+	return zp.skipped, nil
+}
+
+func (zp *zoneParser) parseRoot(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fileError(path, err)
+	}
+	zp.includes = append(zp.includes, abs)
+	if err := zp.parseFile(path); err != nil {
+		if zp.stopped != nil {
+			return err
+		}
+		return fileError(path, err)
+	}
+	return nil
+}
+
+// parseZoneFileData parses the lines of an RFC 1035 master file and returns a zone for each origin
+// records were defined under. Relative names are qualified with the $ORIGIN in effect, falling back
+// to the origin provided when the file does not declare one.
+func parseZoneFileData(data []string, origin string) ([]models.ZoneFile, error) {
+	zp := newZoneParser(origin)
+	var zl zoneLexer
+	for i := range data {
+		if err := zp.parseLine(&zl, data[i], i+1, ""); err != nil {
+			return nil, err
+		}
+	}
+	if err := zp.finish(&zl, ""); err != nil {
+		return nil, err
+	}
+	return zp.result(), nil
+}
+
+func newZoneParser(origin string) *zoneParser {
+	return &zoneParser{
+		origin:   strings.TrimSuffix(origin, "."),
+		index:    make(map[string]int),
+		implicit: make(map[string]bool),
+	}
+}
+
+// parseFile processes the lines of a single file as they are read. The file name is used to resolve
+// relative $INCLUDE paths.
+func (zp *zoneParser) parseFile(path string) error {
+	var zl zoneLexer
+	err := file_management.ScanFileLines(path, func(line string, num int) error {
+		return zp.parseLine(&zl, line, num, path)
+	})
+	if err != nil {
+		return err
+	}
+	return zp.finish(&zl, path)
+}
+
+// parseLine feeds a line to the lexer and processes the entry it completes, if any.
+func (zp *zoneParser) parseLine(zl *zoneLexer, line string, num int, file string) error {
+	entry, err := zl.feed(line, num)
+	if err != nil {
+		return zp.fail(file, num, line, err)
+	}
+	if entry == nil {
+		return nil
+	}
+	if !entry.blank && strings.HasPrefix(entry.tokens[0], "$") {
+		err = zp.directive(entry, file)
+	} else {
+		var rec models.DNSRecord
+		if rec, err = zp.record(entry); err == nil {
+			err = zp.add(rec)
+		}
+	}
+	if err != nil {
+		return zp.fail(file, entry.line, entry.text, err)
+	}
+	return nil
+}
+
+// finish reports an entry left open at the end of a file.
+func (zp *zoneParser) finish(zl *zoneLexer, file string) error {
+	if zl.current != nil {
+		return zp.fail(file, zl.current.line, zl.current.text, errors.New("unbalanced parentheses"))
+	}
+	return nil
+}
+
+// fail returns an error for an entry which could not be parsed or, in lenient mode, records it so
+// parsing can continue. Errors from included files already describe where they occurred, and errors
+// returned while emitting records always end parsing.
+func (zp *zoneParser) fail(file string, line int, text string, err error) error {
+	if zp.stopped != nil {
+		return zp.stopped
+	}
+	pe, ok := err.(*ParseError)
+	if !ok {
+		pe = &ParseError{File: file, Line: line, Text: strings.TrimSpace(text), Err: err}
+	}
+	if !zp.lenient {
+		return pe
+	}
+	zp.skipped = append(zp.skipped, *pe)
+	return nil
+}
+
+// add files a record under the zone for the origin currently in effect, or passes it on when streaming.
+func (zp *zoneParser) add(rec models.DNSRecord) error {
+	if zp.emit != nil {
+		origin := zp.origin
+		if !zp.explicit && rec.Type == "SOA" {
+			zp.renamed[origin] = rec.Name
+		}
+		if renamed, ok := zp.renamed[origin]; ok && !zp.explicit {
+			origin = renamed
+		}
+		if err := zp.emit(ZoneRecord{Origin: origin, Record: rec}); err != nil {
+			zp.stopped = err
+			return err
+		}
+		return nil
+	}
+	i, ok := zp.index[zp.origin]
+	if !ok {
+		i = len(zp.zones)
+		zp.index[zp.origin] = i
+		zp.implicit[zp.origin] = !zp.explicit
+		zp.zones = append(zp.zones, models.ZoneFile{Origin: zp.origin})
+	}
+	zp.zones[i].Records = append(zp.zones[i].Records, rec)
+	return nil
+}
+
+// result returns the parsed zones. Zones whose origin was only derived from the file name take
+// the owner of their SOA record as the origin instead.
+func (zp *zoneParser) result() []models.ZoneFile {
+	for i := range zp.zones {
+		if !zp.implicit[zp.zones[i].Origin] {
+			continue
+		}
+		for _, rec := range zp.zones[i].Records {
+			if rec.Type == "SOA" {
+				zp.zones[i].Origin = rec.Name
+				break
+			}
+		}
+	}
+	return zp.zones
+}
+
+// feed tokenises a line and returns an entry once all of its parentheses have been closed.
+func (zl *zoneLexer) feed(line string, num int) (*zoneEntry, error) {
+	if zl.current == nil {
+		zl.current = &zoneEntry{line: num, text: line, blank: len(line) > 0 && (line[0] == ' ' || line[0] == '\t')}
+	}
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			zl.current.tokens = append(zl.current.tokens, sb.String())
+			sb.Reset()
+		}
+	}
+
+	inQuote := false
+scan:
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			sb.WriteByte(c)
+			sb.WriteByte(line[i+1])
+			i++
+		case inQuote:
+			sb.WriteByte(c)
+			inQuote = c != '"'
+		case c == '"':
+			sb.WriteByte(c)
+			inQuote = true
+		case c == ';':
+			break scan
+		case c == '(':
+			flush()
+			zl.depth++
+		case c == ')':
+			flush()
+			if zl.depth == 0 {
+				zl.current = nil
+				return nil, errors.New("unexpected ')'")
+			}
+			zl.depth--
+		case c == ' ' || c == '\t' || c == '\r':
+			flush()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if inQuote {
+		zl.current = nil
+		zl.depth = 0
+		return nil, errors.New("unterminated quoted string")
+	}
+	flush()
+	if zl.depth > 0 {
+		return nil, nil
+	}
+
+	entry := zl.current
+	zl.current = nil
+	if len(entry.tokens) == 0 {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// directive applies a $ORIGIN, $TTL, $INCLUDE or $GENERATE control entry to the parser state.
+func (zp *zoneParser) directive(e *zoneEntry, file string) error {
+	switch strings.ToUpper(e.tokens[0]) {
+	case "$ORIGIN":
+		if len(e.tokens) < 2 {
+			return errors.New("$ORIGIN requires a domain name")
+		}
+		origin, err := zp.qualify(e.tokens[1])
+		if err != nil {
+			return err
+		}
+		zp.origin = origin
+		zp.explicit = true
+	case "$INCLUDE":
+		return zp.include(e, file)
+	case "$GENERATE":
+		return zp.generate(e)
+	case "$TTL":
+		if len(e.tokens) < 2 {
+			return errors.New("$TTL requires a value")
+		}
+		ttl, ok := parseTTL(e.tokens[1])
+		if !ok {
+			return fmt.Errorf("invalid $TTL value %q", e.tokens[1])
+		}
+		zp.defaultTTL = ttl
+		zp.hasTTL = true
+	default:
+		return fmt.Errorf("unsupported directive %s", e.tokens[0])
+	}
+	return nil
+}
+
+// include parses the file named by an $INCLUDE entry. Its optional origin, and any changes the
+// included file makes to the origin or current owner, do not carry over to the including file.
+func (zp *zoneParser) include(e *zoneEntry, file string) error {
+	if len(e.tokens) < 2 {
+		return errors.New("$INCLUDE requires a file name")
+	}
+	path := strings.Trim(e.tokens[1], `"`)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(file), path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("$INCLUDE %s: %w", path, err)
+	}
+	if slices.Contains(zp.includes, abs) {
+		return fmt.Errorf("$INCLUDE cycle: %s", strings.Join(append(zp.includes, abs), " -> "))
+	}
+	origin, explicit, owner := zp.origin, zp.explicit, zp.lastOwner
+	if len(e.tokens) > 2 {
+		if zp.origin, err = zp.qualify(e.tokens[2]); err != nil {
+			return err
+		}
+		zp.explicit = true
+	}
+	zp.includes = append(zp.includes, abs)
+	err = zp.parseFile(path)
+	zp.includes = zp.includes[:len(zp.includes)-1]
+	zp.origin, zp.explicit, zp.lastOwner = origin, explicit, owner
+	if _, ok := err.(*ParseError); err != nil && !ok && zp.stopped == nil {
+		return fmt.Errorf("$INCLUDE %s: %w", path, err)
+	}
+	return err
+}
+
+// generate expands a BIND $GENERATE entry, i.e., '$GENERATE 1-254 host-$ A 10.0.0.$', into
+// one record per value in its range.
+func (zp *zoneParser) generate(e *zoneEntry) error {
+	if len(e.tokens) < 5 {
+		return errors.New("$GENERATE requires a range, owner, type and data")
+	}
+	start, stop, step, err := parseGenerateRange(e.tokens[1])
+	if err != nil {
+		return err
+	}
+	// The values are counted rather than stepped through, as stepping past a stop near the largest int
+	// would overflow rather than end the loop.
+	for n := 0; n <= (stop-start)/step; n++ {
+		i := start + n*step
+		entry := &zoneEntry{line: e.line, tokens: make([]string, 0, len(e.tokens)-2)}
+		for _, tok := range e.tokens[2:] {
+			sub, err := generateSubstitute(tok, i)
+			if err != nil {
+				return err
+			}
+			entry.tokens = append(entry.tokens, sub)
+		}
+		rec, err := zp.record(entry)
+		if err != nil {
+			return err
+		}
+		if err := zp.add(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record converts an entry into a DNS record, filling in an omitted owner, TTL or class from the
+// preceding entries and qualifying any relative names.
+func (zp *zoneParser) record(e *zoneEntry) (models.DNSRecord, error) {
+	var rec models.DNSRecord
+	fields := e.tokens
+	if e.blank {
+		if zp.lastOwner == "" {
+			return rec, errors.New("record has no owner name")
+		}
+		rec.Name = zp.lastOwner
+	} else {
+		name, err := zp.qualify(fields[0])
+		if err != nil {
+			return rec, err
+		}
+		rec.Name = name
+		fields = fields[1:]
+	}
+
+	hasTTL := false
+	for len(fields) > 0 {
+		if ttl, ok := parseTTL(fields[0]); ok && !hasTTL {
+			rec.TTL = ttl
+			hasTTL = true
+		} else if isClass(fields[0]) && rec.Class == "" {
+			rec.Class = strings.ToUpper(fields[0])
+		} else {
+			break
+		}
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return rec, fmt.Errorf("record for %s has no type", rec.Name)
+	}
+	rec.Type = strings.ToUpper(fields[0])
+
+	if !hasTTL {
+		rec.TTL = zp.lastTTL
+		if zp.hasTTL {
+			rec.TTL = zp.defaultTTL
+		}
+	}
+	if rec.Class == "" {
+		rec.Class = zp.lastClass
+	}
+	if rec.Class == "" {
+		rec.Class = "IN"
+	}
+
+	rr, err := zp.rdata(rec, fields[1:])
+	if err != nil {
+		return rec, err
+	}
+	rec.Content = strings.TrimPrefix(rr.String(), rr.Header().String())
+	setRecordData(&rec, rr)
+
+	// Without $TTL an omitted SOA TTL takes the negative caching value from its MINIMUM field.
+	if rec.SOA != nil && !hasTTL && !zp.hasTTL {
+		rec.TTL = rec.SOA.Minimum
+	}
+
+	zp.lastOwner = rec.Name
+	zp.lastTTL = rec.TTL
+	zp.lastClass = rec.Class
+	return rec, nil
+}
+
+// rdata parses the RDATA of a record, qualifying any relative names with the current origin.
+func (zp *zoneParser) rdata(rec models.DNSRecord, fields []string) (dns.RR, error) {
+	return parseRR(rec.Name, rec.TTL, rec.Class, rec.Type, strings.Join(fields, " "), zp.origin)
+}
+
+// parseRR parses a record from its presentation format fields. Relative names in the RDATA are
+// qualified with the origin, which may be empty when all names are absolute.
+func parseRR(name string, ttl int, class, rtype, rdata, origin string) (dns.RR, error) {
+	text := fmt.Sprintf("%s. %d %s %s %s", strings.TrimSuffix(name, "."), ttl, class, rtype, rdata)
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+	parser := dns.NewZoneParser(strings.NewReader(text), origin, "")
+	rr, ok := parser.Next()
+	if !ok {
+		if err := parser.Err(); err != nil {
+			// The position miekg reports is within the generated text rather than the source file.
+			msg, _, _ := strings.Cut(err.Error(), " at line: ")
+			return nil, errors.New(msg)
+		}
+		return nil, errors.New("empty record")
+	}
+	return rr, nil
+}
+
+// qualify returns a name relative to the current origin as a fully qualified name without the trailing dot.
+func (zp *zoneParser) qualify(name string) (string, error) {
+	switch {
+	case name == "@":
+		if zp.origin == "" {
+			return "", errors.New("'@' used without an origin")
+		}
+		return zp.origin, nil
+	case strings.HasSuffix(name, ".") && !strings.HasSuffix(name, "\\."):
+		return strings.TrimSuffix(name, "."), nil
+	case zp.origin == "":
+		return "", fmt.Errorf("relative name %s used without an origin", name)
+	}
+	return name + "." + zp.origin, nil
+}
+
+// parseTTL parses a TTL given either in seconds or using BIND style units such as 1h30m.
+func parseTTL(s string) (int, bool) {
+	total, num, digits := 0, 0, false
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			num = num*10 + int(c-'0')
+			digits = true
+			if num > 1<<31-1 {
+				return 0, false
+			}
+			continue
+		}
+		unit, ok := ttlUnits[c]
+		if !ok || !digits {
+			return 0, false
+		}
+		total += num * unit
+		num, digits = 0, false
+	}
+	if s == "" || total+num > 1<<31-1 {
+		return 0, false
+	}
+	return total + num, true
+}
+
+// isClass reports whether a token is a class mnemonic or an RFC 3597 CLASSnnn value.
+func isClass(s string) bool {
+	s = strings.ToUpper(s)
+	switch s {
+	case "IN", "CH", "CS", "HS":
+		return true
+	}
+	if n, ok := strings.CutPrefix(s, "CLASS"); ok {
+		_, err := strconv.ParseUint(n, 10, 16)
+		return err == nil
+	}
+	return false
+}
+
+// parseGenerateRange parses a $GENERATE range in the form start-stop[/step].
+func parseGenerateRange(s string) (int, int, int, error) {
+	bounds, stepStr, hasStep := strings.Cut(s, "/")
+	startStr, stopStr, ok := strings.Cut(bounds, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	stop, err := strconv.Atoi(stopStr)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	step := 1
+	if hasStep {
+		if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+			return 0, 0, 0, fmt.Errorf("invalid $GENERATE step %q", s)
+		}
+	}
+	if start < 0 || stop < start {
+		return 0, 0, 0, fmt.Errorf("invalid $GENERATE range %q", s)
+	}
+	if (stop-start)/step >= maxGenerated {
+		return 0, 0, 0, fmt.Errorf("$GENERATE range %q exceeds %d records", s, maxGenerated)
+	}
+	return start, stop, step, nil
+}
+
+// generateSubstitute replaces the '$' and '${offset[,width[,base]]}' iterators in a $GENERATE
+// template. '$$' and '\$' produce a literal '$'.
+func generateSubstitute(s string, i int) (string, error) {
+	var sb strings.Builder
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '\\' && j+1 < len(s) && s[j+1] == '$':
+			sb.WriteByte('$')
+			j++
+		case c != '$':
+			sb.WriteByte(c)
+		case j+1 < len(s) && s[j+1] == '$':
+			sb.WriteByte('$')
+			j++
+		case j+1 < len(s) && s[j+1] == '{':
+			end := strings.IndexByte(s[j:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated $GENERATE modifier in %q", s)
+			}
+			v, err := generateModifier(s[j+2:j+end], i)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			j += end
+		default:
+			sb.WriteString(strconv.Itoa(i))
+		}
+	}
+	return sb.String(), nil
+}
+
+// generateModifier formats the iterator according to a $GENERATE offset, width and base where the
+// base is one of d, o, x, X or the reversed nibble formats n and N used for ip6.arpa names.
+func generateModifier(spec string, i int) (string, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) > 3 {
+		return "", fmt.Errorf("invalid $GENERATE modifier %q", spec)
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid $GENERATE offset %q", spec)
+	}
+	width := 0
+	if len(parts) > 1 {
+		if width, err = strconv.Atoi(parts[1]); err != nil || width < 0 {
+			return "", fmt.Errorf("invalid $GENERATE width %q", spec)
+		}
+	}
+	base := "d"
+	if len(parts) > 2 {
+		base = parts[2]
+	}
+	v := i + offset
+	if v < 0 {
+		return "", fmt.Errorf("$GENERATE modifier %q produces a negative value", spec)
+	}
+
+	switch base {
+	case "d":
+		return fmt.Sprintf("%0*d", width, v), nil
+	case "o":
+		return fmt.Sprintf("%0*o", width, v), nil
+	case "x":
+		return fmt.Sprintf("%0*x", width, v), nil
+	case "X":
+		return fmt.Sprintf("%0*X", width, v), nil
+	case "n", "N":
+		// The width counts the output characters, including the dots between nibbles.
+		digits := fmt.Sprintf("%0*x", (width+1)/2, v)
+		if base == "N" {
+			digits = strings.ToUpper(digits)
+		}
+		nibbles := make([]string, len(digits))
+		for k := range digits {
+			nibbles[len(digits)-1-k] = digits[k : k+1]
+		}
+		return strings.Join(nibbles, "."), nil
+	}
+	return "", fmt.Errorf("invalid $GENERATE base %q", spec)
+}
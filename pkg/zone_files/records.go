@@ -0,0 +1,111 @@
+package zone_files
+
+import (
+	"github.com/miekg/dns"
+	"orbit/models"
+	"strings"
+)
+
+// RecordFromRR converts a parsed resource record into a DNSRecord with typed record data.
+func RecordFromRR(rr dns.RR) models.DNSRecord {
+	hdr := rr.Header()
+	rec := models.DNSRecord{
+		Type:    dns.Type(hdr.Rrtype).String(),
+		Class:   dns.Class(hdr.Class).String(),
+		Name:    trimDot(hdr.Name),
+		Content: strings.TrimPrefix(rr.String(), hdr.String()),
+		TTL:     int(hdr.Ttl),
+	}
+	setRecordData(&rec, rr)
+	return rec
+}
+
+// setRecordData populates the typed fields of a record from its parsed RDATA.
+func setRecordData(rec *models.DNSRecord, rr dns.RR) {
+	switch v := rr.(type) {
+	case *dns.CNAME:
+		rec.Target = trimDot(v.Target)
+	case *dns.DNAME:
+		rec.Target = trimDot(v.Target)
+	case *dns.NS:
+		rec.Target = trimDot(v.Ns)
+	case *dns.PTR:
+		rec.Target = trimDot(v.Ptr)
+	case *dns.TXT:
+		rec.TXT = v.Txt
+	case *dns.MX:
+		rec.MX = &models.MXData{Preference: int(v.Preference), Exchange: trimDot(v.Mx)}
+	case *dns.SRV:
+		rec.SRV = &models.SRVData{
+			Priority: int(v.Priority),
+			Weight:   int(v.Weight),
+			Port:     int(v.Port),
+			Target:   trimDot(v.Target),
+		}
+	case *dns.SOA:
+		rec.SOA = &models.SOAData{
+			MName:   trimDot(v.Ns),
+			RName:   trimDot(v.Mbox),
+			Serial:  v.Serial,
+			Refresh: int(v.Refresh),
+			Retry:   int(v.Retry),
+			Expire:  int(v.Expire),
+			Minimum: int(v.Minttl),
+		}
+	case *dns.CAA:
+		rec.CAA = &models.CAAData{Flag: int(v.Flag), Tag: v.Tag, Value: v.Value}
+	case *dns.SVCB:
+		rec.SVCB = svcbData(v)
+	case *dns.HTTPS:
+		rec.SVCB = svcbData(&v.SVCB)
+	case *dns.DS:
+		rec.DS = &models.DSData{
+			KeyTag:     int(v.KeyTag),
+			Algorithm:  int(v.Algorithm),
+			DigestType: int(v.DigestType),
+			Digest:     v.Digest,
+		}
+	}
+}
+
+func svcbData(v *dns.SVCB) *models.SVCBData {
+	data := &models.SVCBData{Priority: int(v.Priority), Target: trimDot(v.Target)}
+	if len(v.Value) > 0 {
+		data.Params = make(map[string]string, len(v.Value))
+		for _, kv := range v.Value {
+			data.Params[kv.Key().String()] = kv.String()
+		}
+	}
+	return data
+}
+
+// quoteTXT returns a character string in quoted presentation format.
+func quoteTXT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// trimDot removes the trailing dot from a fully qualified name. The root name becomes '.'.
+func trimDot(name string) string {
+	if name == "." {
+		return name
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+// RecordToRR converts a record back into a resource record so its RDATA can be processed by miekg/dns.
+func RecordToRR(rec models.DNSRecord) (dns.RR, error) {
+	return parseRR(rec.Name, rec.TTL, rec.Class, rec.Type, rec.Content, ".")
+}
+
+// CanonicalLess orders names as described in RFC 4034 section 6.1, comparing lowercased labels from the right.
+func CanonicalLess(a, b string) bool {
+	la, lb := dns.SplitDomainName(strings.ToLower(a)), dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		x, y := la[len(la)-i], lb[len(lb)-i]
+		if x != y {
+			return x < y
+		}
+	}
+	return len(la) < len(lb)
+}
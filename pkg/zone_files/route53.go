@@ -0,0 +1,192 @@
+package zone_files
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/miekg/dns"
+	"maps"
+	"orbit/models"
+	"strconv"
+	"strings"
+)
+
+// route53Export is the output of 'aws route53 list-resource-record-sets'.
+type route53Export struct {
+	ResourceRecordSets []route53RecordSet `json:"ResourceRecordSets"`
+}
+
+type route53RecordSet struct {
+	Name             string `json:"Name"`
+	Type             string `json:"Type"`
+	TTL              int    `json:"TTL"`
+	SetIdentifier    string `json:"SetIdentifier"`
+	Weight           *int   `json:"Weight"`
+	Region           string `json:"Region"`
+	Failover         string `json:"Failover"`
+	MultiValueAnswer bool   `json:"MultiValueAnswer"`
+	HealthCheckId    string `json:"HealthCheckId"`
+	GeoLocation      *struct {
+		ContinentCode   string `json:"ContinentCode"`
+		CountryCode     string `json:"CountryCode"`
+		SubdivisionCode string `json:"SubdivisionCode"`
+	} `json:"GeoLocation"`
+	GeoProximityLocation *json.RawMessage `json:"GeoProximityLocation"`
+	CidrRoutingConfig    *json.RawMessage `json:"CidrRoutingConfig"`
+	ResourceRecords      []struct {
+		Value string `json:"Value"`
+	} `json:"ResourceRecords"`
+	AliasTarget *struct {
+		HostedZoneId         string `json:"HostedZoneId"`
+		DNSName              string `json:"DNSName"`
+		EvaluateTargetHealth bool   `json:"EvaluateTargetHealth"`
+	} `json:"AliasTarget"`
+}
+
+// aliasServices maps parts of the DNS names of AWS alias targets to the service behind them.
+var aliasServices = []struct {
+	match   string
+	service string
+}{
+	{".cloudfront.net", "cloudfront"},
+	{".elb.amazonaws.com", "elb"},
+	{"s3-website", "s3"},
+	{".execute-api.", "api-gateway"},
+	{".awsglobalaccelerator.com", "global-accelerator"},
+	{".elasticbeanstalk.com", "elastic-beanstalk"},
+	{".vpce.amazonaws.com", "vpc-endpoint"},
+}
+
+// parseRoute53Data converts a Route 53 record set export into a zone. The export may be the full
+// command output or only its ResourceRecordSets array. The origin is taken from the SOA record set,
+// falling back to the origin provided. In lenient mode record sets which fail to parse are skipped.
+func parseRoute53Data(data []byte, origin string, lenient bool) (models.ZoneFile, []ParseError, error) {
+	var export route53Export
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &export.ResourceRecordSets); err != nil {
+			return models.ZoneFile{}, nil, err
+		}
+	} else if err := json.Unmarshal(data, &export); err != nil {
+		return models.ZoneFile{}, nil, err
+	}
+	if len(export.ResourceRecordSets) == 0 {
+		return models.ZoneFile{}, nil, errors.New("no Route 53 record sets found")
+	}
+
+	zf := models.ZoneFile{Origin: strings.TrimSuffix(origin, ".")}
+	bad := recordSkipper{lenient: lenient}
+	for _, rs := range export.ResourceRecordSets {
+		name := unescapeRoute53Name(rs.Name)
+		if rs.Type == "SOA" {
+			zf.Origin = trimDot(name)
+		}
+		attrs := route53Attributes(rs)
+
+		if rs.AliasTarget != nil {
+			target := trimDot(strings.ToLower(rs.AliasTarget.DNSName))
+			attrs["alias-type"] = rs.Type
+			attrs["alias-hosted-zone-id"] = rs.AliasTarget.HostedZoneId
+			attrs["alias-service"] = route53AliasService(target)
+			zf.Records = append(zf.Records, models.DNSRecord{
+				Type:       "ALIAS",
+				Class:      "IN",
+				Name:       trimDot(name),
+				Content:    target,
+				TTL:        rs.TTL,
+				Target:     target,
+				Attributes: attrs,
+			})
+			continue
+		}
+
+		// Route 53 treats every name as fully qualified whether or not it has a trailing dot.
+		var recs []models.DNSRecord
+		var err error
+		for _, rr := range rs.ResourceRecords {
+			var parsed dns.RR
+			if parsed, err = parseRR(name, rs.TTL, "IN", rs.Type, rr.Value, "."); err != nil {
+				break
+			}
+			rec := RecordFromRR(parsed)
+			rec.Attributes = maps.Clone(attrs)
+			recs = append(recs, rec)
+		}
+		if err != nil {
+			if err := bad.skip(rs.Name+" "+rs.Type, err); err != nil {
+				return models.ZoneFile{}, nil, err
+			}
+			continue
+		}
+		zf.Records = append(zf.Records, recs...)
+	}
+	if zf.Origin == "" {
+		return models.ZoneFile{}, nil, errors.New("unable to determine the zone origin")
+	}
+	return zf, bad.skipped, nil
+}
+
+// route53Attributes records the routing policy of a record set.
+func route53Attributes(rs route53RecordSet) map[string]string {
+	attrs := make(map[string]string)
+	if rs.SetIdentifier != "" {
+		attrs["set-identifier"] = rs.SetIdentifier
+	}
+	if rs.HealthCheckId != "" {
+		attrs["health-check-id"] = rs.HealthCheckId
+	}
+	switch {
+	case rs.Weight != nil:
+		attrs["routing-policy"] = "weighted"
+		attrs["weight"] = strconv.Itoa(*rs.Weight)
+	case rs.Region != "":
+		attrs["routing-policy"] = "latency"
+		attrs["region"] = rs.Region
+	case rs.GeoLocation != nil:
+		attrs["routing-policy"] = "geolocation"
+		var loc []string
+		for _, code := range []string{rs.GeoLocation.ContinentCode, rs.GeoLocation.CountryCode, rs.GeoLocation.SubdivisionCode} {
+			if code != "" {
+				loc = append(loc, code)
+			}
+		}
+		attrs["location"] = strings.Join(loc, "/")
+	case rs.Failover != "":
+		attrs["routing-policy"] = "failover"
+		attrs["failover"] = strings.ToLower(rs.Failover)
+	case rs.MultiValueAnswer:
+		attrs["routing-policy"] = "multivalue"
+	case rs.GeoProximityLocation != nil:
+		attrs["routing-policy"] = "geoproximity"
+	case rs.CidrRoutingConfig != nil:
+		attrs["routing-policy"] = "cidr"
+	}
+	return attrs
+}
+
+// route53AliasService identifies the AWS service an alias target belongs to. Targets which do not
+// belong to a known service are records in a Route 53 hosted zone.
+func route53AliasService(target string) string {
+	for _, as := range aliasServices {
+		if strings.Contains(target, as.match) {
+			return as.service
+		}
+	}
+	return "route53"
+}
+
+// unescapeRoute53Name decodes the octal escapes Route 53 uses in names, i.e., '\052' for '*'.
+// Escaped dots, backslashes, whitespace and control characters are left escaped.
+func unescapeRoute53Name(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+4 <= len(name) {
+			if c, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil && c > ' ' && c < 0x7f && c != '.' && c != '\\' {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,60 @@
+package zone_files
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var route53Sample = []byte(`{
+  "ResourceRecordSets": [
+    {"Name": "example.com.", "Type": "SOA", "TTL": 900, "ResourceRecords": [
+      {"Value": "ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400"}]},
+    {"Name": "example.com.", "Type": "A", "AliasTarget": {
+      "HostedZoneId": "Z2FDTNDATAQYW2", "DNSName": "d111111abcdef8.cloudfront.net.", "EvaluateTargetHealth": false}},
+    {"Name": "\\052.dev.example.com.", "Type": "CNAME", "TTL": 300, "ResourceRecords": [{"Value": "dev.example.com"}]},
+    {"Name": "api.example.com.", "Type": "A", "TTL": 60, "SetIdentifier": "blue", "Weight": 0,
+      "ResourceRecords": [{"Value": "192.0.2.1"}, {"Value": "192.0.2.2"}]},
+    {"Name": "api.example.com.", "Type": "A", "TTL": 60, "SetIdentifier": "eu", "Region": "eu-west-1",
+      "ResourceRecords": [{"Value": "192.0.2.3"}]},
+    {"Name": "example.com.", "Type": "TXT", "TTL": 300, "ResourceRecords": [{"Value": "\"v=spf1 -all\""}]}
+  ]
+}`)
+
+func TestParseRoute53Data(t *testing.T) {
+	zf, _, err := parseRoute53Data(route53Sample, "fallback", false)
+	assert.NoError(t, err)
+
+	t.Run("The origin is taken from the SOA record set.", func(t *testing.T) {
+		assert.Equal(t, "example.com", zf.Origin)
+		assert.Len(t, zf.Records, 7)
+	})
+
+	t.Run("Alias record sets become ALIAS records with their AWS service.", func(t *testing.T) {
+		alias := zf.Records[1]
+		assert.Equal(t, "ALIAS", alias.Type)
+		assert.Equal(t, "d111111abcdef8.cloudfront.net", alias.Target)
+		assert.Equal(t, "cloudfront", alias.Attributes["alias-service"])
+		assert.Equal(t, "A", alias.Attributes["alias-type"])
+	})
+
+	t.Run("Escaped names are decoded.", func(t *testing.T) {
+		assert.Equal(t, "*.dev.example.com", zf.Records[2].Name)
+		assert.Equal(t, "dev.example.com", zf.Records[2].Target)
+	})
+
+	t.Run("Routing policies are kept for every value of a record set.", func(t *testing.T) {
+		assert.Equal(t, "192.0.2.2", zf.Records[4].Content)
+		assert.Equal(t, "weighted", zf.Records[4].Attributes["routing-policy"])
+		assert.Equal(t, "0", zf.Records[4].Attributes["weight"])
+		assert.Equal(t, "latency", zf.Records[5].Attributes["routing-policy"])
+		assert.Equal(t, []string{"v=spf1 -all"}, zf.Records[6].TXT)
+	})
+
+	t.Run("A bare ResourceRecordSets array is accepted.", func(t *testing.T) {
+		zf, _, err := parseRoute53Data([]byte(`[{"Name": "www.example.org.", "Type": "A", "TTL": 60,
+			"ResourceRecords": [{"Value": "192.0.2.1"}]}]`), "example.org", false)
+		assert.NoError(t, err)
+		assert.Equal(t, "example.org", zf.Origin)
+		assert.Equal(t, "www.example.org", zf.Records[0].Name)
+	})
+}
@@ -0,0 +1,99 @@
+package zone_files
+
+import (
+	"errors"
+	"orbit/internal/file_management"
+	"orbit/models"
+	"path/filepath"
+)
+
+// ZoneRecord is a record read from zone data along with the origin of the zone it belongs to.
+type ZoneRecord struct {
+	Origin string
+	Record models.DNSRecord
+}
+
+// zoneCollector groups streamed records into zones in the order their origins first appear.
+type zoneCollector struct {
+	zones []models.ZoneFile
+	index map[string]int
+}
+
+func (zc *zoneCollector) add(rec ZoneRecord) error {
+	if zc.index == nil {
+		zc.index = make(map[string]int)
+	}
+	i, ok := zc.index[rec.Origin]
+	if !ok {
+		i = len(zc.zones)
+		zc.index[rec.Origin] = i
+		zc.zones = append(zc.zones, models.ZoneFile{Origin: rec.Origin})
+	}
+	zc.zones[i].Records = append(zc.zones[i].Records, rec.Record)
+	return nil
+}
+
+// stopError carries an error returned by a stream's consumer so that it ends the stream rather than
+// dropping the file being read.
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stopError) Unwrap() error {
+	return e.err
+}
+
+// StreamZoneData reads the same sources as GetZoneData but passes each record to fn as it is parsed,
+// so that zones with millions of records can be processed without holding them in memory. Master
+// files and tinydns data files are read line by line. JSON and YAML exports are decoded whole, one file
+// at a time. Returning an error from fn stops reading and StreamZoneData returns that error.
+func (dz *DNSZones) StreamZoneData(path string, fn func(ZoneRecord) error) error {
+	consume := func(rec ZoneRecord) error {
+		if err := fn(rec); err != nil {
+			return &stopError{err}
+		}
+		return nil
+	}
+	err := dz.eachZoneSource(path, func(file string) error {
+		return dz.streamZoneSource(file, consume)
+	})
+	var stop *stopError
+	if errors.As(err, &stop) {
+		return stop.err
+	}
+	return err
+}
+
+// streamZoneSource streams the records of a single file, falling back to reading the whole file for
+// formats which cannot be parsed line by line.
+func (dz *DNSZones) streamZoneSource(path string, fn func(ZoneRecord) error) error {
+	isYAML := filepath.Ext(path) == ".yaml" || filepath.Ext(path) == ".yml"
+	if isYAML || file_management.IsJSONFile(path) {
+		zones, err := dz.readZoneSource(path)
+		if err != nil {
+			return err
+		}
+		for _, zf := range zones {
+			for _, rec := range zf.Records {
+				if err := fn(ZoneRecord{Origin: zf.Origin, Record: rec}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	var skipped []ParseError
+	var err error
+	if isTinyDNSLine(file_management.FirstLine(path, ";", "#")) {
+		skipped, err = streamTinyDNSFile(path, dz.Lenient, fn)
+	} else {
+		skipped, err = streamZoneFile(path, zoneOriginFromPath(path), dz.Lenient, fn)
+	}
+	dz.DroppedLines = append(dz.DroppedLines, skipped...)
+	return err
+}
@@ -0,0 +1,116 @@
+package zone_files
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamZoneData(t *testing.T) {
+	dir := t.TempDir()
+	writeZone := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	collect := func(dz *DNSZones, path string) ([]string, error) {
+		var results []string
+		err := dz.StreamZoneData(path, func(zr ZoneRecord) error {
+			results = append(results, zr.Origin+" "+zr.Record.Name+" "+zr.Record.Type)
+			return nil
+		})
+		return results, err
+	}
+
+	t.Run("Master file records are streamed with the origin in effect.", func(t *testing.T) {
+		writeZone("master/hosts.db", "www A 192.0.2.1\n")
+		path := writeZone("master/example.com.zone", "$TTL 60\n"+
+			"@ SOA ns1 hostmaster 1 7200 900 1209600 300\n"+
+			"$INCLUDE hosts.db hosts.example.com.\nmail A 192.0.2.2\n")
+
+		dz := DNSZones{}
+		records, err := collect(&dz, path)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"example.com example.com SOA",
+			"hosts.example.com www.hosts.example.com A",
+			"example.com mail.example.com A",
+		}, records)
+
+		zones, err := dz.GetZoneData(path)
+		assert.NoError(t, err)
+		assert.Len(t, zones, 2)
+	})
+
+	t.Run("Records before an SOA keep the origin derived from the file name.", func(t *testing.T) {
+		path := writeZone("implicit/db.example", "$TTL 60\nwww A 192.0.2.1\n"+
+			"example.net. SOA ns1.example.net. hostmaster.example.net. 1 7200 900 1209600 300\nmail A 192.0.2.2\n")
+
+		records, err := collect(&DNSZones{}, path)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"example www.example A",
+			"example.net example.net SOA",
+			"example.net mail.example A",
+		}, records)
+	})
+
+	t.Run("tinydns records are grouped under SOA origins defined later in the file.", func(t *testing.T) {
+		path := writeZone("tinydns/data", "+www.example.com:192.0.2.10\n.example.com:192.0.2.53:a\n")
+
+		records, err := collect(&DNSZones{}, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "example.com www.example.com A", records[0])
+		assert.Len(t, records, 4)
+	})
+
+	t.Run("JSON exports are streamed after being read whole.", func(t *testing.T) {
+		path := writeZone("json/example.org.json", `[{"Name": "www.example.org.", "Type": "A", "TTL": 60,
+			"ResourceRecords": [{"Value": "192.0.2.1"}]}]`)
+
+		records, err := collect(&DNSZones{}, path)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"example.org www.example.org A"}, records)
+	})
+
+	t.Run("Lenient mode drops bad lines while streaming.", func(t *testing.T) {
+		path := writeZone("lenient/example.com.zone", "$TTL 60\nwww A 192.0.2.1\nftp A not-an-ip\n")
+
+		dz := DNSZones{Lenient: true}
+		records, err := collect(&dz, path)
+		assert.NoError(t, err)
+		assert.Len(t, records, 1)
+		assert.Len(t, dz.DroppedLines, 1)
+		assert.Equal(t, 3, dz.DroppedLines[0].Line)
+
+		_, err = collect(&DNSZones{}, path)
+		assert.ErrorContains(t, err, "line 3")
+	})
+
+	t.Run("An error from the consumer stops the stream and is returned.", func(t *testing.T) {
+		var lines []string
+		for i := 0; i < 100; i++ {
+			lines = append(lines, fmt.Sprintf("host%d A 192.0.2.%d", i, i))
+		}
+		writeZone("stop/example.com.zone", "$TTL 60\n"+strings.Join(lines, "\n"))
+		writeZone("stop/example.org.zone", "$TTL 60\nwww A 192.0.2.1\n")
+
+		stop := errors.New("stop")
+		count := 0
+		dz := DNSZones{}
+		err := dz.StreamZoneData(filepath.Join(dir, "stop"), func(zr ZoneRecord) error {
+			if count++; count == 10 {
+				return stop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 10, count)
+		assert.Empty(t, dz.DroppedFiles)
+	})
+}
@@ -0,0 +1,321 @@
+package zone_files
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"orbit/internal/file_management"
+	"orbit/models"
+	"strconv"
+	"strings"
+)
+
+// Default TTLs applied by tinydns-data when a line does not set one.
+const (
+	tinyDNSNSTTL  = 259200
+	tinyDNSSOATTL = 2560
+	tinyDNSTTL    = 86400
+)
+
+// tinyDNSLineTypes are the leading characters of the tinydns-data line formats.
+const tinyDNSLineTypes = ".&=+@'^CZ:36S"
+
+// isTinyDNSLine reports whether a line uses the tinydns-data format, i.e., '+www.example.com:192.0.2.1'.
+// Master file entries cannot contain ':' in their owner name so the two formats do not overlap.
+func isTinyDNSLine(line string) bool {
+	if line == "" || !strings.ContainsRune(tinyDNSLineTypes+"%", rune(line[0])) {
+		return false
+	}
+	first := strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' })
+	return len(first) > 0 && strings.Contains(first[0], ":")
+}
+
+// streamTinyDNSFile parses a tinydns data file and passes each record to fn as soon as it is parsed,
+// with the origin of the SOA record it falls under, or its last two labels when it is outside every SOA
+// origin. The file is read twice, first for the origins of its SOA records and then for the records
+// themselves, so neither its lines nor its records are held in memory. In lenient mode lines which
+// fail to parse are skipped and returned.
+func streamTinyDNSFile(path string, lenient bool, fn func(ZoneRecord) error) ([]ParseError, error) {
+	origins := make(map[string]bool)
+	err := file_management.ScanFileLines(path, func(line string, num int) error {
+		if line == "" || (line[0] != '.' && line[0] != 'Z') {
+			return nil
+		}
+		recs, _ := tinyDNSLine(line, num, path)
+		for _, rec := range recs {
+			if rec.Type == "SOA" {
+				origins[rec.Name] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fileError(path, err)
+	}
+
+	var skipped []ParseError
+	var stopped error
+	err = file_management.ScanFileLines(path, func(line string, num int) error {
+		recs, pe := tinyDNSLine(line, num, path)
+		if pe != nil {
+			if !lenient {
+				return pe
+			}
+			skipped = append(skipped, *pe)
+			return nil
+		}
+		for _, rec := range recs {
+			if stopped = fn(ZoneRecord{Origin: tinyDNSOrigin(rec.Name, origins), Record: rec}); stopped != nil {
+				return stopped
+			}
+		}
+		return nil
+	})
+	if err != nil && stopped == nil {
+		return skipped, fileError(path, err)
+	}
+	return skipped, err
+}
+
+// tinyDNSLine converts a line of a tinydns data file into the records it defines. Blank lines,
+// comments and the '-' disabled and '%' location lines define none.
+func tinyDNSLine(line string, num int, file string) ([]models.DNSRecord, *ParseError) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' || line[0] == '-' || line[0] == '%' {
+		return nil, nil
+	}
+	recs, err := tinyDNSRecords(line[0], strings.Split(line[1:], ":"))
+	if err != nil {
+		return nil, &ParseError{File: file, Line: num, Text: line, Err: err}
+	}
+	return recs, nil
+}
+
+// tinyDNSRecords converts one tinydns-data line into the records it defines.
+func tinyDNSRecords(kind byte, f []string) ([]models.DNSRecord, error) {
+	field := func(i int) string {
+		if i < len(f) {
+			return tinyDNSUnescape(f[i])
+		}
+		return ""
+	}
+	ttl := func(i, def int) (int, error) {
+		if field(i) == "" {
+			return def, nil
+		}
+		return strconv.Atoi(field(i))
+	}
+	fqdn := strings.ToLower(trimDot(field(0)))
+	if fqdn == "" {
+		return nil, fmt.Errorf("'%c' line has no name", kind)
+	}
+
+	var b tinyDNSBuilder
+	switch kind {
+	case '.', '&':
+		t, err := ttl(3, tinyDNSNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		ns := tinyDNSHost(field(2), "ns", fqdn)
+		if kind == '.' {
+			b.add(fqdn, tinyDNSSOATTL, "SOA", fmt.Sprintf("%s. hostmaster.%s. 0 16384 2048 1048576 2560", ns, fqdn))
+		}
+		b.add(fqdn, t, "NS", ns+".")
+		b.addIP(ns, t, field(1), false)
+	case '=', '+':
+		t, err := ttl(2, tinyDNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		b.addIP(fqdn, t, field(1), kind == '=')
+	case '3', '6':
+		t, err := ttl(2, tinyDNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := hex.DecodeString(field(1))
+		if err != nil || len(raw) != net.IPv6len {
+			return nil, fmt.Errorf("invalid IPv6 address %q", field(1))
+		}
+		b.addIP(fqdn, t, net.IP(raw).String(), kind == '6')
+	case '@':
+		t, err := ttl(4, tinyDNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		mx := tinyDNSHost(field(2), "mx", fqdn)
+		dist := field(3)
+		if dist == "" {
+			dist = "0"
+		}
+		b.add(fqdn, t, "MX", dist+" "+mx+".")
+		b.addIP(mx, t, field(1), false)
+	case 'S':
+		t, err := ttl(6, tinyDNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		target := tinyDNSHost(field(2), "srv", fqdn)
+		b.add(fqdn, t, "SRV", fmt.Sprintf("%s %s %s %s.", zeroIfEmpty(field(4)), zeroIfEmpty(field(5)), field(3), target))
+		b.addIP(target, t, field(1), false)
+	case '\'':
+		t, err := ttl(2, tinyDNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		b.add(fqdn, t, "TXT", tinyDNSTXT(field(1)))
+	case '^', 'C':
+		t, err := ttl(2, tinyDNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		rtype := "PTR"
+		if kind == 'C' {
+			rtype = "CNAME"
+		}
+		b.add(fqdn, t, rtype, trimDot(field(1))+".")
+	case 'Z':
+		t, err := ttl(8, tinyDNSSOATTL)
+		if err != nil {
+			return nil, err
+		}
+		b.add(fqdn, t, "SOA", fmt.Sprintf("%s. %s. %s %s %s %s %s", trimDot(field(1)), trimDot(field(2)),
+			zeroIfEmpty(field(3)), defaultIfEmpty(field(4), "16384"), defaultIfEmpty(field(5), "2048"),
+			defaultIfEmpty(field(6), "1048576"), defaultIfEmpty(field(7), "2560")))
+	case ':':
+		t, err := ttl(3, tinyDNSTTL)
+		if err != nil {
+			return nil, err
+		}
+		raw := field(2)
+		b.add(fqdn, t, "TYPE"+field(1), fmt.Sprintf("\\# %d %s", len(raw), hex.EncodeToString([]byte(raw))))
+	default:
+		return nil, fmt.Errorf("unsupported line type '%c'", kind)
+	}
+	return b.records, b.err
+}
+
+// tinyDNSBuilder collects the records produced by a line, keeping the first error encountered.
+type tinyDNSBuilder struct {
+	records []models.DNSRecord
+	err     error
+}
+
+func (b *tinyDNSBuilder) add(name string, ttl int, rtype, rdata string) {
+	if b.err != nil {
+		return
+	}
+	rr, err := parseRR(name, ttl, "IN", rtype, rdata, ".")
+	if err != nil {
+		b.err = fmt.Errorf("%s %s: %w", name, rtype, err)
+		return
+	}
+	b.records = append(b.records, RecordFromRR(rr))
+}
+
+// addIP adds an A or AAAA record for a host, and the matching PTR record when requested.
+// An empty address adds nothing, as tinydns-data does for '.', '&' and '@' lines.
+func (b *tinyDNSBuilder) addIP(host string, ttl int, addr string, ptr bool) {
+	if addr == "" {
+		return
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		b.err = fmt.Errorf("invalid IP address %q", addr)
+		return
+	}
+	rtype := "AAAA"
+	if ip.To4() != nil {
+		rtype = "A"
+	}
+	b.add(host, ttl, rtype, ip.String())
+	if ptr {
+		if rev, err := dns.ReverseAddr(ip.String()); err == nil {
+			b.add(rev, ttl, "PTR", host+".")
+		}
+	}
+}
+
+// tinyDNSHost qualifies a nameserver, mail exchanger or SRV target. Names without a dot are placed
+// under the given label of the domain, i.e., 'a' for example.com becomes 'a.ns.example.com'.
+func tinyDNSHost(x, label, fqdn string) string {
+	x = strings.ToLower(trimDot(x))
+	if strings.Contains(x, ".") {
+		return x
+	}
+	return x + "." + label + "." + fqdn
+}
+
+// tinyDNSOrigin returns the longest SOA origin a name falls under.
+func tinyDNSOrigin(name string, origins map[string]bool) string {
+	for parent := name; parent != ""; {
+		if origins[parent] {
+			return parent
+		}
+		_, parent, _ = strings.Cut(parent, ".")
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) <= 2 {
+		return name
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+// tinyDNSUnescape decodes the \nnn octal escapes tinydns-data uses for bytes such as ':'.
+func tinyDNSUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// tinyDNSTXT splits a TXT value into character strings of at most 255 bytes and quotes them,
+// escaping non-printable bytes.
+func tinyDNSTXT(s string) string {
+	var segments []string
+	for len(s) > 255 {
+		segments = append(segments, s[:255])
+		s = s[255:]
+	}
+	segments = append(segments, s)
+	for i := range segments {
+		var sb strings.Builder
+		for _, c := range []byte(segments[i]) {
+			switch {
+			case c == '"' || c == '\\':
+				sb.WriteByte('\\')
+				sb.WriteByte(c)
+			case c < ' ' || c > '~':
+				fmt.Fprintf(&sb, "\\%03d", c)
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		segments[i] = `"` + sb.String() + `"`
+	}
+	return strings.Join(segments, " ")
+}
+
+func zeroIfEmpty(s string) string {
+	return defaultIfEmpty(s, "0")
+}
+
+func defaultIfEmpty(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package zone_files
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var tinyDNSSample = `# tinydns data
+.example.com:192.0.2.53:a:259200
+=www.example.com:192.0.2.10:300
++api.example.com:192.0.2.11
+@example.com:192.0.2.25:mail:10
+Cblog.example.com:hosting.example.net
+'example.com:v=spf1 mx -all
+^11.2.0.192.in-addr.arpa:api.example.com
+:example.com:16:\003abc
+-disabled.example.com:192.0.2.99
+`
+
+func TestStreamTinyDNSFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	assert.NoError(t, os.WriteFile(path, []byte(tinyDNSSample), 0o644))
+	var zc zoneCollector
+	_, err := streamTinyDNSFile(path, false, zc.add)
+	assert.NoError(t, err)
+	zones := zc.zones
+
+	t.Run("Records are grouped under the SOA origin or their last two labels.", func(t *testing.T) {
+		assert.Len(t, zones, 2)
+		assert.Equal(t, "example.com", zones[0].Origin)
+		assert.Equal(t, "in-addr.arpa", zones[1].Origin)
+		assert.Len(t, zones[1].Records, 2)
+	})
+
+	t.Run("'.' lines define the SOA, NS and nameserver address.", func(t *testing.T) {
+		recs := zones[0].Records
+		assert.Equal(t, "SOA", recs[0].Type)
+		assert.Equal(t, "a.ns.example.com", recs[0].SOA.MName)
+		assert.Equal(t, "a.ns.example.com", recs[1].Target)
+		assert.Equal(t, "a.ns.example.com", recs[2].Name)
+		assert.Equal(t, "192.0.2.53", recs[2].Content)
+	})
+
+	t.Run("Host, mail, alias and text lines are converted.", func(t *testing.T) {
+		recs := zones[0].Records
+		assert.Equal(t, "www.example.com", recs[3].Name)
+		assert.Equal(t, 300, recs[3].TTL)
+		assert.Equal(t, 86400, recs[4].TTL)
+		assert.Equal(t, "mail.mx.example.com", recs[5].MX.Exchange)
+		assert.Equal(t, "hosting.example.net", recs[7].Target)
+		assert.Equal(t, []string{"v=spf1 mx -all"}, recs[8].TXT)
+		assert.Equal(t, []string{"abc"}, recs[9].TXT)
+		assert.Len(t, recs, 10)
+	})
+
+	t.Run("'=' lines also add a PTR record.", func(t *testing.T) {
+		assert.Equal(t, "10.2.0.192.in-addr.arpa", zones[1].Records[0].Name)
+		assert.Equal(t, "www.example.com", zones[1].Records[0].Target)
+	})
+
+	t.Run("Data files are detected by GetZoneData.", func(t *testing.T) {
+		dz := DNSZones{}
+		read, err := dz.GetZoneData(path)
+		assert.NoError(t, err)
+		assert.Equal(t, zones, read)
+	})
+}
@@ -0,0 +1,182 @@
+package zone_files
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"orbit/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZoneTransfer describes a zone to request from an authoritative server.
+type ZoneTransfer struct {
+	Zone   string
+	Server string // Host or IP address with an optional port, defaulting to 53.
+	TSIG   *TSIGKey
+	// Serial requests an IXFR of the changes since this serial instead of a full AXFR.
+	Serial  uint32
+	Timeout time.Duration
+}
+
+// TSIGKey is a shared secret used to sign zone transfer requests.
+type TSIGKey struct {
+	Name      string
+	Algorithm string
+	Secret    string // Base64 encoded.
+}
+
+var tsigAlgorithms = map[string]string{
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// ParseTSIGKey parses a key in dig's '[algorithm:]name:secret' format. The algorithm defaults to hmac-sha256.
+func ParseTSIGKey(s string) (*TSIGKey, error) {
+	parts := strings.Split(s, ":")
+	algorithm := "hmac-sha256"
+	switch len(parts) {
+	case 2:
+	case 3:
+		algorithm = strings.ToLower(parts[0])
+		parts = parts[1:]
+	default:
+		return nil, errors.New("TSIG key must be in the format [algorithm:]name:secret")
+	}
+	if _, ok := tsigAlgorithms[algorithm]; !ok {
+		return nil, fmt.Errorf("unsupported TSIG algorithm %s", algorithm)
+	}
+	if parts[0] == "" || parts[1] == "" {
+		return nil, errors.New("TSIG key name and secret are required")
+	}
+	return &TSIGKey{Name: parts[0], Algorithm: algorithm, Secret: parts[1]}, nil
+}
+
+// ParseZoneTransfers parses a comma separated list of 'zone@server[:port][#serial]' transfer targets.
+// Targets with a serial request an IXFR of the changes since it rather than a full AXFR.
+func ParseZoneTransfers(s string, key *TSIGKey) ([]ZoneTransfer, error) {
+	var results []ZoneTransfer
+	for _, target := range strings.Split(s, ",") {
+		target = strings.TrimSpace(target)
+		rest, serial, incremental := strings.Cut(target, "#")
+		zone, server, ok := strings.Cut(rest, "@")
+		if !ok || zone == "" || server == "" {
+			return nil, fmt.Errorf("invalid zone transfer target %q, expected zone@server[#serial]", target)
+		}
+		xfr := ZoneTransfer{Zone: zone, Server: server, TSIG: key}
+		if incremental {
+			n, err := strconv.ParseUint(serial, 10, 32)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid serial in zone transfer target %q", target)
+			}
+			xfr.Serial = uint32(n)
+		}
+		results = append(results, xfr)
+	}
+	return results, nil
+}
+
+// TransferZone requests a zone from an authoritative server. A full AXFR returns every record in
+// the zone. An IXFR returns the records changed since the requested serial, with each marked as
+// 'added' or 'deleted' in its 'ixfr' attribute, unless the server falls back to a full transfer.
+func (dz *DNSZones) TransferZone(xfr ZoneTransfer) (models.ZoneFile, error) {
+	rrs, err := transferRRs(xfr)
+	if err != nil {
+		return models.ZoneFile{}, err
+	}
+	zf := models.ZoneFile{Origin: trimDot(dns.Fqdn(xfr.Zone))}
+	if isIncrementalTransfer(rrs) {
+		zf.Records = ixfrRecords(rrs)
+		return zf, nil
+	}
+	// A full transfer starts and ends with the SOA record.
+	for _, rr := range rrs[:len(rrs)-1] {
+		zf.Records = append(zf.Records, RecordFromRR(rr))
+	}
+	return zf, nil
+}
+
+// transferRRs performs the transfer and returns the answer records of every message received.
+func transferRRs(xfr ZoneTransfer) ([]dns.RR, error) {
+	server := xfr.Server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	timeout := xfr.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	m := new(dns.Msg)
+	if xfr.Serial > 0 {
+		m.SetIxfr(dns.Fqdn(xfr.Zone), xfr.Serial, ".", ".")
+	} else {
+		m.SetAxfr(dns.Fqdn(xfr.Zone))
+	}
+	t := &dns.Transfer{DialTimeout: timeout, ReadTimeout: timeout, WriteTimeout: timeout}
+	if xfr.TSIG != nil {
+		name := dns.CanonicalName(xfr.TSIG.Name)
+		t.TsigSecret = map[string]string{name: xfr.TSIG.Secret}
+		m.SetTsig(name, tsigAlgorithms[xfr.TSIG.Algorithm], 300, time.Now().Unix())
+	}
+
+	env, err := t.In(m, server)
+	if err != nil {
+		if t.Conn != nil {
+			_ = t.Close()
+		}
+		return nil, fmt.Errorf("zone transfer of %s from %s: %w", xfr.Zone, xfr.Server, err)
+	}
+	var rrs []dns.RR
+	for e := range env {
+		if e.Error != nil {
+			err = e.Error
+			continue
+		}
+		rrs = append(rrs, e.RR...)
+	}
+	if t.Conn != nil {
+		_ = t.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("zone transfer of %s from %s: %w", xfr.Zone, xfr.Server, err)
+	}
+	// An IXFR response holding only the SOA means the zone has not changed since the serial.
+	if len(rrs) == 0 || (len(rrs) == 1 && xfr.Serial == 0) {
+		return nil, fmt.Errorf("zone transfer of %s from %s returned no records", xfr.Zone, xfr.Server)
+	}
+	return rrs, nil
+}
+
+// isIncrementalTransfer reports whether an IXFR response holds differences, which start with the new
+// SOA followed by the SOA of the old version, rather than a full zone.
+func isIncrementalTransfer(rrs []dns.RR) bool {
+	return len(rrs) > 2 && rrs[0].Header().Rrtype == dns.TypeSOA && rrs[1].Header().Rrtype == dns.TypeSOA
+}
+
+// ixfrRecords converts IXFR difference sequences, each an old SOA and deleted records followed by a new
+// SOA and added records, into records marked as deleted or added.
+func ixfrRecords(rrs []dns.RR) []models.DNSRecord {
+	var results []models.DNSRecord
+	change := "added"
+	for _, rr := range rrs[1 : len(rrs)-1] {
+		// Each SOA marks the switch between the deletions and additions of a sequence.
+		if rr.Header().Rrtype == dns.TypeSOA {
+			if change == "added" {
+				change = "deleted"
+			} else {
+				change = "added"
+			}
+			continue
+		}
+		rec := RecordFromRR(rr)
+		rec.Attributes = map[string]string{"ixfr": change}
+		results = append(results, rec)
+	}
+	return results
+}
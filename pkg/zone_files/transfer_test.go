@@ -0,0 +1,140 @@
+package zone_files
+
+import (
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var transferZone = `$ORIGIN example.com.
+$TTL 300
+@	SOA	ns1 hostmaster 2024010101 3600 900 1209600 300
+@	NS	ns1
+ns1	A	192.0.2.53
+www	A	192.0.2.10
+app	CNAME	www
+`
+
+const transferSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+
+// serveTransfers starts a local TCP server which answers AXFR requests for the fixture zone. When
+// a key name is given, unsigned requests are refused.
+func serveTransfers(t *testing.T, keyName string) string {
+	var rrs []dns.RR
+	zp := dns.NewZoneParser(strings.NewReader(transferZone), "", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	assert.NoError(t, zp.Err())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := &dns.Server{Listener: listener, Net: "tcp"}
+	if keyName != "" {
+		srv.TsigSecret = map[string]string{dns.Fqdn(keyName): transferSecret}
+	}
+	srv.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if keyName != "" && (r.IsTsig() == nil || w.TsigStatus() != nil) {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeRefused)
+			_ = w.WriteMsg(m)
+			return
+		}
+		ch := make(chan *dns.Envelope)
+		tr := new(dns.Transfer)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			_ = tr.Out(w, r, ch)
+			wg.Done()
+		}()
+		ch <- &dns.Envelope{RR: append(rrs, rrs[0])}
+		close(ch)
+		wg.Wait()
+	})
+
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return listener.Addr().String()
+}
+
+func TestTransferZone(t *testing.T) {
+	dz := DNSZones{}
+
+	t.Run("An AXFR returns every record once.", func(t *testing.T) {
+		addr := serveTransfers(t, "")
+		zf, err := dz.TransferZone(ZoneTransfer{Zone: "example.com", Server: addr})
+		assert.NoError(t, err)
+		assert.Equal(t, "example.com", zf.Origin)
+		assert.Len(t, zf.Records, 5)
+		assert.Equal(t, "SOA", zf.Records[0].Type)
+		assert.Equal(t, "www.example.com", zf.Records[4].Target)
+	})
+
+	t.Run("Transfers can be signed with TSIG.", func(t *testing.T) {
+		addr := serveTransfers(t, "transfer-key")
+		_, err := dz.TransferZone(ZoneTransfer{Zone: "example.com", Server: addr})
+		assert.Error(t, err)
+
+		key, err := ParseTSIGKey("hmac-sha256:transfer-key:" + transferSecret)
+		assert.NoError(t, err)
+		zf, err := dz.TransferZone(ZoneTransfer{Zone: "example.com", Server: addr, TSIG: key})
+		assert.NoError(t, err)
+		assert.Len(t, zf.Records, 5)
+	})
+}
+
+func TestParseZoneTransfers(t *testing.T) {
+	t.Run("Targets are given as zone@server.", func(t *testing.T) {
+		xfrs, err := ParseZoneTransfers("example.com@ns1.example.com, example.org@192.0.2.53:5353", nil)
+		assert.NoError(t, err)
+		assert.Len(t, xfrs, 2)
+		assert.Equal(t, "192.0.2.53:5353", xfrs[1].Server)
+
+		_, err = ParseZoneTransfers("example.com", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Targets with a serial request an IXFR.", func(t *testing.T) {
+		xfrs, err := ParseZoneTransfers("example.com@192.0.2.53:5353#2024010101,example.org@ns1.example.org", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "192.0.2.53:5353", xfrs[0].Server)
+		assert.Equal(t, uint32(2024010101), xfrs[0].Serial)
+		assert.Zero(t, xfrs[1].Serial)
+
+		for _, target := range []string{"example.com@ns1.example.com#", "example.com@ns1.example.com#abc", "example.com@ns1.example.com#0"} {
+			_, err = ParseZoneTransfers(target, nil)
+			assert.Error(t, err, target)
+		}
+	})
+}
+
+func TestIxfrRecords(t *testing.T) {
+	t.Run("Incremental transfers mark deleted and added records.", func(t *testing.T) {
+		var rrs []dns.RR
+		for _, s := range []string{
+			"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 3 3600 900 1209600 300",
+			"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 2 3600 900 1209600 300",
+			"old.example.com. 300 IN A 192.0.2.1",
+			"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 3 3600 900 1209600 300",
+			"new.example.com. 300 IN A 192.0.2.2",
+			"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 3 3600 900 1209600 300",
+		} {
+			rr, err := dns.NewRR(s)
+			assert.NoError(t, err)
+			rrs = append(rrs, rr)
+		}
+		assert.True(t, isIncrementalTransfer(rrs))
+		recs := ixfrRecords(rrs)
+		assert.Len(t, recs, 2)
+		assert.Equal(t, "deleted", recs[0].Attributes["ixfr"])
+		assert.Equal(t, "new.example.com", recs[1].Name)
+		assert.Equal(t, "added", recs[1].Attributes["ixfr"])
+	})
+}
@@ -0,0 +1,161 @@
+package zone_files
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/miekg/dns"
+	"io"
+	"log"
+	"orbit/models"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WriteZoneFile writes a zone as a canonical RFC 1035 master file: an $ORIGIN line, the SOA record and
+// then the remaining records sorted by owner name in DNSSEC canonical order, type and data, with
+// duplicates removed. Owner names are relative to the origin and every record gives its TTL and class.
+// Provider specific records such as ALIAS, and the attributes of imported records, are kept as comments.
+func (dz *DNSZones) WriteZoneFile(w io.Writer, zone models.ZoneFile) error {
+	origin := dns.Fqdn(zone.Origin)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s\n", origin)
+
+	written := make(map[string]bool, len(zone.Records))
+	for _, rec := range sortRecords(zone.Records) {
+		key := strings.ToLower(rec.Name) + " " + rec.Class + " " + rec.Type + " " + rec.Content
+		if written[key] {
+			continue
+		}
+		written[key] = true
+
+		prefix := ""
+		if _, ok := dns.StringToType[rec.Type]; !ok && !strings.HasPrefix(rec.Type, "TYPE") {
+			prefix = "; "
+		}
+		content := rec.Content
+		if rec.Type == "ALIAS" && rec.Target != "" {
+			content = dns.Fqdn(rec.Target)
+		}
+		fmt.Fprintf(bw, "%s%s\t%d\t%s\t%s\t%s", prefix, relativeName(rec.Name, origin), rec.TTL, rec.Class, rec.Type, content)
+		if len(rec.Attributes) > 0 {
+			fmt.Fprintf(bw, " ; %s", formatAttributes(rec.Attributes))
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// WriteZoneFiles writes each zone to '<origin>.zone' in a directory, combining zones which share an
+// origin, and returns the paths written.
+func (dz *DNSZones) WriteZoneFiles(dir string, zones []models.ZoneFile) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var origins []string
+	combined := make(map[string]*models.ZoneFile)
+	for _, zf := range zones {
+		origin := strings.ToLower(strings.TrimSuffix(zf.Origin, "."))
+		if combined[origin] == nil {
+			origins = append(origins, origin)
+			combined[origin] = &models.ZoneFile{Origin: origin}
+		}
+		combined[origin].Records = append(combined[origin].Records, zf.Records...)
+	}
+
+	var paths []string
+	for _, origin := range origins {
+		path, err := zoneFilePath(dir, origin)
+		if err != nil {
+			return paths, err
+		}
+		if err := dz.writeZoneFileTo(path, *combined[origin]); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// zoneFilePath returns the file in a directory a zone is written to. Origins come from the zones read,
+// so origins which are not domain names or would name a file outside the directory are rejected.
+func zoneFilePath(dir, origin string) (string, error) {
+	if origin == "" || origin == "." {
+		return filepath.Join(dir, "root.zone"), nil
+	}
+	if _, ok := dns.IsDomainName(origin); !ok || strings.ContainsAny(origin, `/\`) || strings.Contains(origin, "..") {
+		return "", fmt.Errorf("invalid zone origin %q", origin)
+	}
+	path := filepath.Join(dir, origin+".zone")
+	if rel, err := filepath.Rel(dir, path); err != nil || rel != filepath.Base(path) {
+		return "", fmt.Errorf("zone origin %q names a file outside %s", origin, dir)
+	}
+	return path, nil
+}
+
+func (dz *DNSZones) writeZoneFileTo(path string, zone models.ZoneFile) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("error closing file: %s", file.Name())
+		}
+	}(file)
+	return dz.WriteZoneFile(file, zone)
+}
+
+// sortRecords returns the records of a zone with the SOA first, followed by the rest in canonical order.
+func sortRecords(records []models.DNSRecord) []models.DNSRecord {
+	sorted := append([]models.DNSRecord(nil), records...)
+	typeOrder := func(rec models.DNSRecord) int {
+		if t, ok := dns.StringToType[rec.Type]; ok {
+			return int(t)
+		}
+		// Provider specific types follow the standard ones.
+		return 1 << 16
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.Type == "SOA") != (b.Type == "SOA") {
+			return a.Type == "SOA"
+		}
+		if !strings.EqualFold(a.Name, b.Name) {
+			return CanonicalLess(a.Name, b.Name)
+		}
+		if typeOrder(a) != typeOrder(b) {
+			return typeOrder(a) < typeOrder(b)
+		}
+		return a.Content < b.Content
+	})
+	return sorted
+}
+
+// relativeName returns a name relative to the origin, '@' for the origin itself, or the fully
+// qualified name when it is outside the origin.
+func relativeName(name, origin string) string {
+	fqdn := dns.Fqdn(name)
+	switch {
+	case strings.EqualFold(fqdn, origin):
+		return "@"
+	case origin != "." && dns.IsSubDomain(origin, fqdn):
+		return fqdn[:len(fqdn)-len(origin)-1]
+	}
+	return fqdn
+}
+
+func formatAttributes(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + attrs[k]
+	}
+	return strings.Join(parts, " ")
+}
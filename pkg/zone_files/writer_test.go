@@ -0,0 +1,76 @@
+package zone_files
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"orbit/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteZoneFile(t *testing.T) {
+	dz := DNSZones{}
+	zones, err := parseZoneFileData(strings.Split(bindZone, "\n"), "example.com")
+	assert.NoError(t, err)
+
+	t.Run("Written zones parse back into the same records.", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, dz.WriteZoneFile(&buf, zones[0]))
+		parsed, err := parseZoneFileData(strings.Split(buf.String(), "\n"), "")
+		assert.NoError(t, err)
+		assert.Len(t, parsed, 1)
+		assert.Equal(t, "example.com", parsed[0].Origin)
+		assert.ElementsMatch(t, zones[0].Records, parsed[0].Records)
+	})
+
+	t.Run("Records are sorted canonically with the SOA first and duplicates removed.", func(t *testing.T) {
+		zone := models.ZoneFile{Origin: "example.com", Records: []models.DNSRecord{
+			{Type: "A", Class: "IN", Name: "www.example.com", Content: "192.0.2.2", TTL: 60},
+			{Type: "A", Class: "IN", Name: "a.www.example.com", Content: "192.0.2.3", TTL: 60},
+			{Type: "MX", Class: "IN", Name: "example.com", Content: "10 mail.example.net.", TTL: 60},
+			{Type: "A", Class: "IN", Name: "www.example.com", Content: "192.0.2.1", TTL: 60},
+			{Type: "A", Class: "IN", Name: "www.example.com", Content: "192.0.2.1", TTL: 60},
+			{Type: "SOA", Class: "IN", Name: "example.com", Content: "ns1.example.com. hostmaster.example.com. 1 2 3 4 5", TTL: 60},
+			{Type: "A", Class: "IN", Name: "other.example.net", Content: "192.0.2.4", TTL: 60},
+			{Type: "ALIAS", Class: "IN", Name: "example.com", Content: "d111.cloudfront.net", TTL: 60,
+				Target: "d111.cloudfront.net", Attributes: map[string]string{"alias-service": "cloudfront"}},
+		}}
+		var buf bytes.Buffer
+		assert.NoError(t, dz.WriteZoneFile(&buf, zone))
+		assert.Equal(t, `$ORIGIN example.com.
+@	60	IN	SOA	ns1.example.com. hostmaster.example.com. 1 2 3 4 5
+@	60	IN	MX	10 mail.example.net.
+; @	60	IN	ALIAS	d111.cloudfront.net. ; alias-service=cloudfront
+www	60	IN	A	192.0.2.1
+www	60	IN	A	192.0.2.2
+a.www	60	IN	A	192.0.2.3
+other.example.net.	60	IN	A	192.0.2.4
+`, buf.String())
+	})
+}
+
+func TestWriteZoneFiles(t *testing.T) {
+	dz := DNSZones{}
+	record := []models.DNSRecord{{Type: "A", Class: "IN", Name: "www.example.com", Content: "192.0.2.1", TTL: 60}}
+
+	t.Run("Zones are written to files named after their origins.", func(t *testing.T) {
+		dir := t.TempDir()
+		paths, err := dz.WriteZoneFiles(dir, []models.ZoneFile{{Origin: "Example.com.", Records: record}, {Origin: "example.com"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "example.com.zone")}, paths)
+	})
+
+	t.Run("Origins which would name files outside the directory are rejected.", func(t *testing.T) {
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "out")
+		for _, origin := range []string{"../../etc/x.", "a/../../b", "..example.com", `..\x`} {
+			_, err := dz.WriteZoneFiles(dir, []models.ZoneFile{{Origin: origin, Records: record}})
+			assert.Error(t, err, origin)
+		}
+		entries, err := os.ReadDir(parent)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
@@ -1,132 +1,329 @@
 package zone_files
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"io/fs"
+	"log"
 	"orbit/internal/file_management"
 	"orbit/models"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 type DNSZones struct {
 	zoneFile models.ZoneFile
+	// Include and Exclude are glob patterns matched against the names and relative paths of the files
+	// found in a directory or archive. Without Include patterns every recognised zone file is read.
+	Include []string
+	Exclude []string
+	// Lenient skips the lines of master and tinydns files, and the record sets of provider exports,
+	// which fail to parse rather than the whole file.
+	Lenient bool
+	// DroppedFiles holds the errors of files skipped while reading a directory or archive and
+	// DroppedLines the lines and record sets skipped in lenient mode.
+	DroppedFiles []ParseError
+	DroppedLines []ParseError
 }
 
+// GetZoneData reads the zones in a zone file, a .tar.gz or .zip archive of zone files or a directory,
+// which is searched recursively.
 func (dz *DNSZones) GetZoneData(path string) ([]models.ZoneFile, error) {
 	var zf []models.ZoneFile
-	fileInfo, err := os.Stat(path)
+	err := dz.eachZoneSource(path, func(file string) error {
+		records, err := dz.readZoneSource(file)
+		if err != nil {
+			return err
+		}
+		zf = append(zf, records...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return zf, nil
+}
+
+// eachZoneSource calls read with a zone file, or with every selected zone file in an archive or below
+// a directory. Files in archives and directories which fail to read are added to DroppedFiles, unless
+// the error stopped a stream.
+func (dz *DNSZones) eachZoneSource(path string, read func(file string) error) error {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
 	switch mode := fileInfo.Mode(); {
 	case mode.IsDir():
-		records, err := readZoneFileDirectory(path)
+		count, err := dz.walkZoneFiles(path, read, nil)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if len(records) == 0 {
-			return nil, errors.New("no zone files found in directory: " + path)
+		if count == 0 {
+			return errors.New("no zone files found in directory: " + path)
 		}
-		zf = records
-	case mode.IsRegular():
-		fb, err := file_management.ReadFileLines(path)
+	case mode.IsRegular() && file_management.IsArchive(path):
+		count, err := dz.walkZoneArchive(path, read, file_management.NewExtractionBudget())
 		if err != nil {
-			return nil, err
+			return err
 		}
-		fd, err := parseZoneFileData(fb)
-		if err != nil {
-			return nil, err
+		if count == 0 {
+			return errors.New("no zone files found in archive: " + path)
 		}
-		zf = append(zf, fd)
+	case mode.IsRegular():
+		return read(path)
 	default:
-		return nil, errors.New("invalid zone file case")
+		return errors.New("invalid zone file case")
 	}
-
-	return zf, nil
+	return nil
 }
 
-func readZoneFileDirectory(path string) ([]models.ZoneFile, error) {
-	var results []models.ZoneFile
-	files, err := os.ReadDir(path)
+// walkZoneFiles walks a directory and reads every selected zone file and archive within it, returning
+// the number of files read. Files named by the $INCLUDE directives of other files are read as part of
+// those files only. Archives are extracted within the budget of the archive the directory was
+// extracted from, or their own when it is nil.
+func (dz *DNSZones) walkZoneFiles(root string, read func(file string) error, budget *file_management.ExtractionBudget) (int, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && matchesGlob(dz.Exclude, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dz.selected(d, rel) {
+			files = append(files, path)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	zoneFiles := make([]os.DirEntry, 0)
-	for _, file := range files {
-		if file_management.IsZoneFile(file) {
-			zoneFiles = append(zoneFiles, file)
+
+	count := 0
+	included := includedFiles(files)
+	for _, path := range files {
+		if abs, err := filepath.Abs(path); err == nil && included[abs] {
+			continue
+		}
+		n := 1
+		if !file_management.IsArchive(path) {
+			err = read(path)
+		} else if budget == nil {
+			n, err = dz.walkZoneArchive(path, read, file_management.NewExtractionBudget())
+		} else {
+			n, err = dz.walkZoneArchive(path, read, budget)
+		}
+		var stop *stopError
+		switch {
+		case errors.As(err, &stop):
+			return count, err
+		case err != nil:
+			dz.DroppedFiles = append(dz.DroppedFiles, *fileError(path, err))
+		default:
+			count += n
 		}
 	}
-	for i := range zoneFiles {
-		zfLines, err := file_management.ReadFileLines(path + "/" + zoneFiles[i].Name())
-		if err != nil {
+	return count, nil
+}
+
+// includedFiles returns the absolute paths of the files named by the $INCLUDE directives of a list of
+// master files.
+func includedFiles(files []string) map[string]bool {
+	included := make(map[string]bool)
+	for _, file := range files {
+		ext := filepath.Ext(file)
+		if file_management.IsArchive(file) || ext == ".json" || ext == ".yaml" || ext == ".yml" {
 			continue
 		}
-		zfData, err := parseZoneFileData(zfLines)
+		_ = file_management.ScanFileLines(file, func(line string, num int) error {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || !strings.EqualFold(fields[0], "$INCLUDE") {
+				return nil
+			}
+			path := strings.Trim(fields[1], `"`)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(file), path)
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				included[abs] = true
+			}
+			return nil
+		})
+	}
+	return included
+}
+
+// walkZoneArchive extracts an archive into a temporary directory within an extraction budget and reads
+// it as a directory. Archives nested within it share the budget.
+func (dz *DNSZones) walkZoneArchive(path string, read func(file string) error, budget *file_management.ExtractionBudget) (int, error) {
+	dir, err := os.MkdirTemp("", "orbit-zones-")
+	if err != nil {
+		return 0, err
+	}
+	defer func(dir string) {
+		err := os.RemoveAll(dir)
 		if err != nil {
-			continue
+			log.Printf("error removing directory: %s", dir)
+		}
+	}(dir)
+
+	if err := file_management.ExtractArchive(path, dir, budget); err != nil {
+		return 0, fileError(path, err)
+	}
+	files, lines := len(dz.DroppedFiles), len(dz.DroppedLines)
+	count, err := dz.walkZoneFiles(dir, read, budget)
+	// Report problems against the archive rather than the temporary directory.
+	for _, dropped := range [][]ParseError{dz.DroppedFiles[files:], dz.DroppedLines[lines:]} {
+		for i := range dropped {
+			dropped[i].File = strings.Replace(dropped[i].File, dir, path, 1)
 		}
-		results = append(results, zfData)
 	}
-	return results, err
+	return count, err
 }
 
-func parseZoneFileData(data []string) (models.ZoneFile, error) {
-	var zf models.ZoneFile
+// selected reports whether a file found in a directory or archive should be read.
+func (dz *DNSZones) selected(d fs.DirEntry, rel string) bool {
+	if matchesGlob(dz.Exclude, rel) {
+		return false
+	}
+	if len(dz.Include) > 0 {
+		return matchesGlob(dz.Include, rel)
+	}
+	return file_management.IsZoneFile(d) || file_management.IsArchive(rel)
+}
 
-	zf.Origin = strings.Split(data[0], " ")[1]
-	zf.Origin = strings.TrimSuffix(zf.Origin, ".")
-	i := 0
-	for _, l := range data {
-		var zr models.DNSRecord
-		l = cleanTabsAndSpaces(l)
-		sections := strings.Split(l, " ")
-		if i > 0 {
-			zr.Name = sections[0]
-			ttl, err := strconv.Atoi(sections[1])
-			if err != nil {
-				return models.ZoneFile{}, err
-			}
-			zr.TTL = ttl
-			zr.Class = sections[2]
-			zr.Type = sections[3]
-			con := sections[4]
-			if !strings.Contains(con, " ") {
-				zr.Content = con
-			} else {
-				zr.Content = parseRecordContentField(sections)
-			}
-			zf.Records = append(zf.Records, zr)
+// matchesGlob reports whether any pattern matches either the name or the slash separated relative path of a file.
+func matchesGlob(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(rel)); ok {
+			return true
 		}
-		i++
 	}
-	return zf, nil
+	return false
 }
 
-func parseRecordContentField(record []string) string {
-	var res []string
-	for i := range record {
-		if i > 5 {
-			res = append(res, record[i])
+// readZoneSource parses a single file as an RFC 1035 master file, a tinydns data file, an octoDNS
+// YAML zone config or one of the supported JSON record set exports.
+func (dz *DNSZones) readZoneSource(path string) ([]models.ZoneFile, error) {
+	origin := zoneOriginFromPath(path)
+	isYAML := filepath.Ext(path) == ".yaml" || filepath.Ext(path) == ".yml"
+	if !isYAML && !file_management.IsJSONFile(path) {
+		var zones []models.ZoneFile
+		var skipped []ParseError
+		var err error
+		if isTinyDNSLine(file_management.FirstLine(path, ";", "#")) {
+			var zc zoneCollector
+			skipped, err = streamTinyDNSFile(path, dz.Lenient, zc.add)
+			zones = zc.zones
+		} else {
+			zones, skipped, err = parseZoneFile(path, origin, dz.Lenient)
+		}
+		if err != nil {
+			return nil, err
 		}
+		dz.DroppedLines = append(dz.DroppedLines, skipped...)
+		return zones, nil
+	}
+	data, err := file_management.ReadFileBytes(path)
+	if err != nil {
+		return nil, fileError(path, err)
+	}
+	var zf models.ZoneFile
+	var skipped []ParseError
+	if isYAML {
+		zf, skipped, err = parseOctoDNSData(data, origin, dz.Lenient)
+	} else {
+		zf, skipped, err = parseJSONZoneData(data, origin, dz.Lenient)
+	}
+	if err != nil {
+		return nil, fileError(path, err)
 	}
-	return strings.Join(res, " ")
+	dz.dropRecordSets(path, skipped)
+	return []models.ZoneFile{zf}, nil
 }
 
-func cleanTabsAndSpaces(record string) string {
-	runes := []rune(record)
-	var sb strings.Builder
-	var last rune
-	for _, r := range runes {
-		if r == '\t' {
-			r = ' '
-		}
-		if r == ' ' && last == r {
-			continue
+// dropRecordSets notes the record sets of a provider export skipped in lenient mode.
+func (dz *DNSZones) dropRecordSets(path string, skipped []ParseError) {
+	for _, pe := range skipped {
+		pe.File = path
+		dz.DroppedLines = append(dz.DroppedLines, pe)
+	}
+}
+
+// parseJSONZoneData detects whether a JSON export came from AWS Route 53, Azure DNS or Google Cloud
+// DNS and parses it accordingly.
+func parseJSONZoneData(data []byte, origin string, lenient bool) (models.ZoneFile, []ParseError, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseRoute53Data(data, origin, lenient)
+	}
+	var probe []struct {
+		Kind            string          `json:"kind"`
+		Type            string          `json:"type"`
+		Rrdatas         json.RawMessage `json:"rrdatas"`
+		ResourceRecords json.RawMessage `json:"ResourceRecords"`
+		AliasTarget     json.RawMessage `json:"AliasTarget"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return models.ZoneFile{}, nil, err
+	}
+	if len(probe) == 0 {
+		return models.ZoneFile{}, nil, errors.New("no record sets found")
+	}
+	switch p := probe[0]; {
+	case p.Kind == "dns#resourceRecordSet" || p.Rrdatas != nil:
+		return parseGCloudData(data, origin, lenient)
+	case strings.HasPrefix(strings.ToLower(p.Type), "microsoft.network/dnszones/"):
+		return parseAzureData(data, origin, lenient)
+	case p.ResourceRecords != nil || p.AliasTarget != nil:
+		return parseRoute53Data(data, origin, lenient)
+	}
+	return models.ZoneFile{}, nil, errors.New("unrecognised JSON zone export")
+}
+
+// zoneOriginFromPath derives a fallback origin from a zone file name, i.e., 'example.com.zone',
+// BIND's 'db.example.com' and octoDNS's 'example.com.yaml' become 'example.com'. Reverse zones
+// named after their network, i.e., 'db.192.0.2', become '2.0.192.in-addr.arpa'. It qualifies
+// relative names in files which do not declare a $ORIGIN.
+func zoneOriginFromPath(path string) string {
+	base := filepath.Base(path)
+	switch ext := filepath.Ext(base); ext {
+	case ".zone", ".db", ".json", ".yaml", ".yml":
+		base = strings.TrimSuffix(base, ext)
+	}
+	base = strings.TrimSuffix(strings.TrimPrefix(base, "db."), ".")
+	if octets, ok := networkOctets(base); ok {
+		slices.Reverse(octets)
+		return strings.Join(octets, ".") + ".in-addr.arpa"
+	}
+	return base
+}
+
+// networkOctets splits a name made of one to three decimal octets, i.e., '192.0.2'.
+func networkOctets(name string) ([]string, bool) {
+	octets := strings.Split(name, ".")
+	if len(octets) > 3 {
+		return nil, false
+	}
+	for _, octet := range octets {
+		if n, err := strconv.Atoi(octet); err != nil || n < 0 || n > 255 || octet != strconv.Itoa(n) {
+			return nil, false
 		}
-		last = r
-		sb.WriteString(string(r))
 	}
-	return sb.String()
+	return octets, true
 }
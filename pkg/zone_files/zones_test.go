@@ -0,0 +1,441 @@
+package zone_files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"orbit/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var bindZone = `; Exported from BIND
+$TTL 1h
+@	IN	SOA	ns1 hostmaster (
+		2024010101 ; serial
+		3600       ; refresh
+		900        ; retry
+		1209600    ; expire
+		300 )      ; minimum
+	IN	NS	ns1
+	IN	NS	ns2.example.net.
+
+ns1		A	192.0.2.1
+www	300	IN	A	192.0.2.10
+	IN	300	AAAA	2001:db8::10
+mail		CNAME	www
+@		TXT	"v=spf1 include:_spf.example.net ~all" "second; segment"
+*.dev		A	192.0.2.20
+`
+
+func TestParseZoneFileData(t *testing.T) {
+	zones, err := parseZoneFileData(strings.Split(bindZone, "\n"), "example.com.")
+	assert.NoError(t, err)
+	assert.Len(t, zones, 1)
+	zf := zones[0]
+
+	t.Run("Origin is taken from the SOA owner when there is no $ORIGIN.", func(t *testing.T) {
+		assert.Equal(t, "example.com", zf.Origin)
+		assert.Len(t, zf.Records, 9)
+	})
+
+	t.Run("Parenthesised records with comments are joined into one record.", func(t *testing.T) {
+		soa := zf.Records[0]
+		assert.Equal(t, "SOA", soa.Type)
+		assert.Equal(t, "example.com", soa.Name)
+		assert.Equal(t, 3600, soa.TTL)
+		assert.Equal(t, "ns1.example.com. hostmaster.example.com. 2024010101 3600 900 1209600 300", soa.Content)
+		assert.Equal(t, uint32(2024010101), soa.SOA.Serial)
+		assert.Equal(t, 300, soa.SOA.Minimum)
+	})
+
+	t.Run("Omitted owner, TTL and class are inherited.", func(t *testing.T) {
+		ns := zf.Records[1]
+		assert.Equal(t, "example.com", ns.Name)
+		assert.Equal(t, "IN", ns.Class)
+		assert.Equal(t, 3600, ns.TTL)
+		assert.Equal(t, "ns1.example.com.", ns.Content)
+		assert.Equal(t, "ns2.example.net.", zf.Records[2].Content)
+
+		aaaa := zf.Records[5]
+		assert.Equal(t, "www.example.com", aaaa.Name)
+		assert.Equal(t, "AAAA", aaaa.Type)
+		assert.Equal(t, 300, aaaa.TTL)
+	})
+
+	t.Run("Relative names in record data are fully qualified.", func(t *testing.T) {
+		assert.Equal(t, "mail.example.com", zf.Records[6].Name)
+		assert.Equal(t, "www.example.com.", zf.Records[6].Content)
+		assert.Equal(t, "www.example.com", zf.Records[6].Target)
+	})
+
+	t.Run("Quoted TXT strings keep their spaces and semicolons.", func(t *testing.T) {
+		assert.Equal(t, `"v=spf1 include:_spf.example.net ~all" "second; segment"`, zf.Records[7].Content)
+	})
+
+	t.Run("Wildcard owners are qualified.", func(t *testing.T) {
+		assert.Equal(t, "*.dev.example.com", zf.Records[8].Name)
+	})
+}
+
+func TestParseZoneFileDataOrigin(t *testing.T) {
+	t.Run("$ORIGIN takes precedence over the file name.", func(t *testing.T) {
+		zones, err := parseZoneFileData([]string{"$ORIGIN example.org.", "www 60 IN A 192.0.2.1"}, "fallback")
+		assert.NoError(t, err)
+		assert.Equal(t, "example.org", zones[0].Origin)
+		assert.Equal(t, "www.example.org", zones[0].Records[0].Name)
+	})
+
+	t.Run("Each $ORIGIN section becomes its own zone.", func(t *testing.T) {
+		zones, err := parseZoneFileData([]string{
+			"$ORIGIN example.org.",
+			"www 60 IN A 192.0.2.1",
+			"$ORIGIN example.net.",
+			"www 60 IN A 192.0.2.2",
+			"$ORIGIN example.org.",
+			"api 60 IN A 192.0.2.3",
+		}, "")
+		assert.NoError(t, err)
+		assert.Len(t, zones, 2)
+		assert.Equal(t, "example.org", zones[0].Origin)
+		assert.Len(t, zones[0].Records, 2)
+		assert.Equal(t, "example.net", zones[1].Origin)
+		assert.Equal(t, "www.example.net", zones[1].Records[0].Name)
+	})
+
+	t.Run("Relative names without any origin are rejected.", func(t *testing.T) {
+		_, err := parseZoneFileData([]string{"www 60 IN A 192.0.2.1"}, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestParseZoneFileIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeZone := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	t.Run("Includes are resolved relative to the including file and restore the origin.", func(t *testing.T) {
+		writeZone("parts/hosts.db", "$INCLUDE more.db\nwww A 192.0.2.1\n")
+		writeZone("parts/more.db", "api A 192.0.2.2\n")
+		root := writeZone("example.com.zone", "$ORIGIN example.com.\n$TTL 60\n"+
+			"$INCLUDE parts/hosts.db hosts.example.com.\nmail A 192.0.2.3\n")
+
+		zones, _, err := parseZoneFile(root, "example.com", false)
+		assert.NoError(t, err)
+		assert.Len(t, zones, 2)
+		assert.Equal(t, "hosts.example.com", zones[0].Origin)
+		assert.Equal(t, "api.hosts.example.com", zones[0].Records[0].Name)
+		assert.Equal(t, "www.hosts.example.com", zones[0].Records[1].Name)
+		assert.Equal(t, "example.com", zones[1].Origin)
+		assert.Equal(t, "mail.example.com", zones[1].Records[0].Name)
+	})
+
+	t.Run("Reverse zones named after their network get an in-addr.arpa origin.", func(t *testing.T) {
+		path := writeZone("db.192.0.2", "$TTL 60\n10 PTR www.example.com.\n")
+
+		zones, _, err := parseZoneFile(path, zoneOriginFromPath(path), false)
+		assert.NoError(t, err)
+		assert.Equal(t, "2.0.192.in-addr.arpa", zones[0].Origin)
+		assert.Equal(t, "10.2.0.192.in-addr.arpa", zones[0].Records[0].Name)
+		assert.Equal(t, "www.example.com", zones[0].Records[0].Target)
+		assert.Equal(t, "example.com", zoneOriginFromPath("db.example.com"))
+	})
+
+	t.Run("Include cycles are detected.", func(t *testing.T) {
+		writeZone("a.zone", "$ORIGIN example.com.\n$INCLUDE b.zone\n")
+		writeZone("b.zone", "$INCLUDE a.zone\n")
+
+		_, _, err := parseZoneFile(filepath.Join(dir, "a.zone"), "", false)
+		assert.ErrorContains(t, err, "cycle")
+	})
+}
+
+func TestGetZoneDataDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	writeZone := func(name, content string) {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	writeZone("example.com.zone", "$TTL 60\n@ NS ns1.example.com.\n")
+	writeZone("bind/db.example.org", "$TTL 60\n@ NS ns1.example.org.\n")
+	writeZone("bind/old/example.net.db", "$TTL 60\n@ NS ns1.example.net.\n")
+	writeZone("notes.txt", "not a zone\n")
+
+	origins := func(zones []models.ZoneFile) []string {
+		var results []string
+		for _, zf := range zones {
+			results = append(results, zf.Origin)
+		}
+		return results
+	}
+
+	t.Run("Directories are searched recursively for .db and db.* files.", func(t *testing.T) {
+		dz := DNSZones{}
+		zones, err := dz.GetZoneData(dir)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"example.com", "example.org", "example.net"}, origins(zones))
+	})
+
+	t.Run("Include and exclude globs select files and directories.", func(t *testing.T) {
+		dz := DNSZones{Include: []string{"db.*", "*.db"}, Exclude: []string{"bind/old"}}
+		zones, err := dz.GetZoneData(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"example.org"}, origins(zones))
+	})
+
+	t.Run("Files read through $INCLUDE are not read again on their own.", func(t *testing.T) {
+		included := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(included, "a-hosts.zone"), []byte("www 60 A 192.0.2.1\n"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(included, "example.com.zone"),
+			[]byte("$ORIGIN example.com.\n$TTL 60\n@ NS ns1.example.com.\n$INCLUDE a-hosts.zone\n"), 0o644))
+
+		dz := DNSZones{}
+		zones, err := dz.GetZoneData(included)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"example.com"}, origins(zones))
+		assert.Len(t, zones[0].Records, 2)
+	})
+
+	t.Run("Zones are read from .tar.gz and .zip archives.", func(t *testing.T) {
+		content := []byte("$TTL 60\n@ NS ns1.example.com.\n")
+		archives := t.TempDir()
+
+		var zipBuf bytes.Buffer
+		zw := zip.NewWriter(&zipBuf)
+		w, err := zw.Create("zones/example.com.zone")
+		assert.NoError(t, err)
+		_, _ = w.Write(content)
+		assert.NoError(t, zw.Close())
+		zipPath := filepath.Join(archives, "zones.zip")
+		assert.NoError(t, os.WriteFile(zipPath, zipBuf.Bytes(), 0o644))
+
+		var tarBuf bytes.Buffer
+		gz := gzip.NewWriter(&tarBuf)
+		tw := tar.NewWriter(gz)
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "db.example.com", Mode: 0o644, Size: int64(len(content))}))
+		_, _ = tw.Write(content)
+		assert.NoError(t, tw.Close())
+		assert.NoError(t, gz.Close())
+		tarPath := filepath.Join(archives, "zones.tar.gz")
+		assert.NoError(t, os.WriteFile(tarPath, tarBuf.Bytes(), 0o644))
+
+		dz := DNSZones{}
+		for _, path := range []string{zipPath, tarPath} {
+			zones, err := dz.GetZoneData(path)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"example.com"}, origins(zones))
+		}
+		zones, err := dz.GetZoneData(archives)
+		assert.NoError(t, err)
+		assert.Len(t, zones, 2)
+	})
+
+	t.Run("Archive entries outside the extraction directory are rejected.", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("../escape.zone")
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte("$TTL 60\n"))
+		assert.NoError(t, zw.Close())
+		path := filepath.Join(t.TempDir(), "bad.zip")
+		assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+		dz := DNSZones{}
+		_, err = dz.GetZoneData(path)
+		assert.ErrorContains(t, err, "outside")
+	})
+}
+
+func TestParseZoneFileDataGenerate(t *testing.T) {
+	t.Run("$GENERATE expands into one record per value in the range.", func(t *testing.T) {
+		zones, err := parseZoneFileData([]string{
+			"$ORIGIN example.com.",
+			"$GENERATE 1-10/3 host-$ 300 A 10.0.0.$",
+		}, "")
+		assert.NoError(t, err)
+		recs := zones[0].Records
+		assert.Len(t, recs, 4)
+		assert.Equal(t, "host-1.example.com", recs[0].Name)
+		assert.Equal(t, "10.0.0.10", recs[3].Content)
+		assert.Equal(t, 300, recs[3].TTL)
+	})
+
+	t.Run("Offset, width and base modifiers are applied.", func(t *testing.T) {
+		zones, err := parseZoneFileData([]string{
+			"$ORIGIN 2.0.192.in-addr.arpa.",
+			"$TTL 60",
+			"$GENERATE 10-11 $ PTR node${-9,3,d}-${0,0,x}-$$.example.com.",
+		}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "10.2.0.192.in-addr.arpa", zones[0].Records[0].Name)
+		assert.Equal(t, "node001-a-$.example.com.", zones[0].Records[0].Content)
+		assert.Equal(t, "node002-b-$.example.com.", zones[0].Records[1].Content)
+	})
+
+	t.Run("Nibble modifiers produce reversed ip6.arpa labels.", func(t *testing.T) {
+		v, err := generateModifier("0,7,n", 0x1a)
+		assert.NoError(t, err)
+		assert.Equal(t, "a.1.0.0", v)
+	})
+
+	t.Run("Invalid ranges are rejected.", func(t *testing.T) {
+		_, err := parseZoneFileData([]string{"$GENERATE 10-1 host-$ A 10.0.0.$"}, "example.com")
+		assert.Error(t, err)
+	})
+
+	t.Run("Ranges ending near the largest int end after their last value.", func(t *testing.T) {
+		zones, err := parseZoneFileData([]string{
+			"$ORIGIN example.com.",
+			"$GENERATE 0-9223372036854775807/4611686018427387904 host-$ 300 TXT generated",
+		}, "")
+		assert.NoError(t, err)
+		assert.Len(t, zones[0].Records, 2)
+		assert.Equal(t, "host-4611686018427387904.example.com", zones[0].Records[1].Name)
+	})
+}
+
+func TestParseZoneFileDataTypedRecords(t *testing.T) {
+	zones, err := parseZoneFileData([]string{
+		"$ORIGIN example.com.",
+		"$TTL 60",
+		"@ MX 10 mail",
+		"_sip._tcp SRV 10 20 5060 sip.example.net.",
+		"@ CAA 0 issue \"letsencrypt.org\"",
+		"@ HTTPS 1 . alpn=h2,h3",
+		"@ DS 12345 13 2 3F3C5C1F3B1A3CC7A7FEB8A1B8A3C4B7E5E1E7D1D0C1C2A7F2F4A7B1C9D7E6F1",
+		"@ TXT \"one\" \"two\"",
+		"4.3.2.1.in-addr.arpa. PTR host",
+	}, "")
+	assert.NoError(t, err)
+	recs := zones[0].Records
+
+	t.Run("MX and SRV records expose their targets.", func(t *testing.T) {
+		assert.Equal(t, 10, recs[0].MX.Preference)
+		assert.Equal(t, "mail.example.com", recs[0].MX.Exchange)
+		assert.Equal(t, 5060, recs[1].SRV.Port)
+		assert.Equal(t, "sip.example.net", recs[1].SRV.Target)
+	})
+
+	t.Run("CAA, HTTPS, DS and TXT records are typed.", func(t *testing.T) {
+		assert.Equal(t, "issue", recs[2].CAA.Tag)
+		assert.Equal(t, "letsencrypt.org", recs[2].CAA.Value)
+		assert.Equal(t, ".", recs[3].SVCB.Target)
+		assert.Equal(t, "h2,h3", recs[3].SVCB.Params["alpn"])
+		assert.Equal(t, 12345, recs[4].DS.KeyTag)
+		assert.Equal(t, []string{"one", "two"}, recs[5].TXT)
+		assert.Equal(t, "host.example.com", recs[6].Target)
+	})
+}
+
+func TestParseJSONZoneData(t *testing.T) {
+	t.Run("Route 53, Azure and Google Cloud exports are detected.", func(t *testing.T) {
+		for _, sample := range [][]byte{route53Sample, azureSample, gcloudSample} {
+			zf, _, err := parseJSONZoneData(sample, "", false)
+			assert.NoError(t, err)
+			assert.Equal(t, "example.com", zf.Origin)
+		}
+	})
+
+	t.Run("Unknown JSON is rejected.", func(t *testing.T) {
+		_, _, err := parseJSONZoneData([]byte(`[{"hostname": "www"}]`), "", false)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseZoneFileDataErrors(t *testing.T) {
+	t.Run("Unbalanced parentheses are reported.", func(t *testing.T) {
+		_, err := parseZoneFileData([]string{"@ 60 IN SOA ns1 host ( 1 2 3 4"}, "example.com")
+		assert.ErrorContains(t, err, "line 1")
+	})
+
+	t.Run("Invalid record data is reported with its line number.", func(t *testing.T) {
+		_, err := parseZoneFileData([]string{"$TTL 60", "www A not-an-ip"}, "example.com")
+		assert.ErrorContains(t, err, "line 2")
+	})
+}
+
+func TestGetZoneDataDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "example.com.zone")
+	assert.NoError(t, os.WriteFile(bad, []byte("$TTL 60\nwww A 192.0.2.1\nftp A not-an-ip\nmail A 192.0.2.2\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "example.org.zone"), []byte("$TTL 60\n@ A 192.0.2.3\n"), 0o644))
+
+	t.Run("Parse errors give the file, line and offending text.", func(t *testing.T) {
+		dz := DNSZones{}
+		_, err := dz.GetZoneData(bad)
+		var pe *ParseError
+		assert.ErrorAs(t, err, &pe)
+		assert.Equal(t, bad, pe.File)
+		assert.Equal(t, 3, pe.Line)
+		assert.Equal(t, "ftp A not-an-ip", pe.Text)
+	})
+
+	t.Run("Files which fail in a directory are recorded as dropped.", func(t *testing.T) {
+		dz := DNSZones{}
+		zones, err := dz.GetZoneData(dir)
+		assert.NoError(t, err)
+		assert.Len(t, zones, 1)
+		assert.Len(t, dz.DroppedFiles, 1)
+		assert.Equal(t, 3, dz.DroppedFiles[0].Line)
+	})
+
+	t.Run("Lenient mode skips bad lines and keeps the rest.", func(t *testing.T) {
+		dz := DNSZones{Lenient: true}
+		zones, err := dz.GetZoneData(bad)
+		assert.NoError(t, err)
+		assert.Len(t, zones[0].Records, 2)
+		assert.Equal(t, "mail.example.com", zones[0].Records[1].Name)
+		assert.Len(t, dz.DroppedLines, 1)
+		assert.Equal(t, "ftp A not-an-ip", dz.DroppedLines[0].Text)
+	})
+
+	t.Run("Lenient mode skips bad record sets of provider exports and keeps the rest.", func(t *testing.T) {
+		exports := map[string]string{
+			"route53.json": `[{"Name": "www.example.com.", "Type": "A", "TTL": 60, "ResourceRecords": [{"Value": "192.0.2.1"}]},
+				{"Name": "ftp.example.com.", "Type": "A", "TTL": 60, "ResourceRecords": [{"Value": "192.0.2.2"}, {"Value": "not-an-ip"}]}]`,
+			"gcloud.json": `[{"kind": "dns#resourceRecordSet", "name": "www.example.com.", "type": "A", "ttl": 60, "rrdatas": ["192.0.2.1"]},
+				{"kind": "dns#resourceRecordSet", "name": "ftp.example.com.", "type": "A", "ttl": 60, "rrdatas": ["not-an-ip"]}]`,
+			"example.com.yaml": "www:\n  type: A\n  value: 192.0.2.1\nftp:\n  type: A\n  value: not-an-ip\n",
+		}
+		for name, data := range exports {
+			path := filepath.Join(t.TempDir(), name)
+			assert.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+
+			_, err := (&DNSZones{}).GetZoneData(path)
+			assert.ErrorContains(t, err, "ftp.example.com", name)
+
+			dz := DNSZones{Lenient: true}
+			zones, err := dz.GetZoneData(path)
+			assert.NoError(t, err, name)
+			assert.Len(t, zones[0].Records, 1, name)
+			assert.Equal(t, "www.example.com", zones[0].Records[0].Name, name)
+			assert.Len(t, dz.DroppedLines, 1, name)
+			assert.Equal(t, path, dz.DroppedLines[0].File, name)
+			assert.Contains(t, dz.DroppedLines[0].Text, "ftp.example.com", name)
+		}
+	})
+}
+
+func TestParseTTL(t *testing.T) {
+	t.Run("TTLs may be plain seconds or use BIND units.", func(t *testing.T) {
+		ttl, ok := parseTTL("1h30m")
+		assert.True(t, ok)
+		assert.Equal(t, 5400, ttl)
+
+		ttl, ok = parseTTL("86400")
+		assert.True(t, ok)
+		assert.Equal(t, 86400, ttl)
+
+		_, ok = parseTTL("www")
+		assert.False(t, ok)
+	})
+}
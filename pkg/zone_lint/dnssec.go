@@ -0,0 +1,394 @@
+package zone_lint
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/miekg/dns"
+	"math/big"
+	"orbit/models"
+	"orbit/pkg/zone_files"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// signatureExpiryWarning is how far ahead signatures about to expire are reported.
+const signatureExpiryWarning = 7 * 24 * time.Hour
+
+// minRSABits is the shortest RSA modulus which is not reported as weak.
+const minRSABits = 2048
+
+// weakAlgorithms are the DNSSEC algorithms RFC 8624 says must not or should not be used for signing.
+var weakAlgorithms = map[uint8]bool{
+	dns.RSAMD5:           true,
+	dns.DSA:              true,
+	dns.RSASHA1:          true,
+	dns.DSANSEC3SHA1:     true,
+	dns.RSASHA1NSEC3SHA1: true,
+	dns.ECCGOST:          true,
+}
+
+type rrsetKey struct {
+	name   string
+	rrtype uint16
+}
+
+// signedZone holds the records of a zone as RRsets keyed by canonical owner name and type.
+type signedZone struct {
+	origin      string
+	rrsets      map[rrsetKey][]dns.RR
+	sigs        map[rrsetKey][]*dns.RRSIG
+	keys        []*dns.DNSKEY
+	nsec        map[string]*dns.NSEC
+	nsec3       map[string]*dns.NSEC3 // Keyed by the hashed owner label.
+	nsec3param  *dns.NSEC3PARAM
+	delegations []string
+}
+
+// ValidateDNSSEC verifies the signatures of a signed zone against its DNSKEYs, checks its NSEC or NSEC3
+// chain covers every name and reports weak keys and signatures which have expired or are about to.
+// Zones without DNSKEY records at the apex are not checked.
+func (zl *ZoneLinter) ValidateDNSSEC(zone *models.ZoneFile, now time.Time) []models.ZoneFinding {
+	z := newSignedZone(zone)
+	if len(z.keys) == 0 {
+		return nil
+	}
+	var findings []models.ZoneFinding
+	add := func(name string, rrtype uint16, check, severity, detail string) {
+		findings = append(findings, models.ZoneFinding{
+			Zone:     zone.Origin,
+			Name:     strings.TrimSuffix(name, "."),
+			Type:     dns.Type(rrtype).String(),
+			Check:    check,
+			Severity: severity,
+			Detail:   detail,
+		})
+	}
+
+	for _, key := range z.keys {
+		if weakAlgorithms[key.Algorithm] {
+			add(z.origin, dns.TypeDNSKEY, "dnssec-weak-algorithm", SeverityMedium,
+				fmt.Sprintf("key %d uses %s", key.KeyTag(), dns.AlgorithmToString[key.Algorithm]))
+		}
+		if bits := rsaKeyBits(key); bits > 0 && bits < minRSABits {
+			add(z.origin, dns.TypeDNSKEY, "dnssec-short-key", SeverityMedium,
+				fmt.Sprintf("key %d is a %d bit RSA key", key.KeyTag(), bits))
+		}
+	}
+
+	for _, k := range z.sortedKeys() {
+		rrset := z.rrsets[k]
+		if ds, ok := rrset[0].(*dns.DS); ok && ds.DigestType == dns.SHA1 {
+			add(k.name, k.rrtype, "dnssec-weak-digest", SeverityLow, "DS record uses a SHA-1 digest")
+		}
+		if !z.authoritative(k) {
+			continue
+		}
+		sigs := z.sigs[k]
+		if len(sigs) == 0 {
+			add(k.name, k.rrtype, "dnssec-missing-signature", SeverityHigh, "RRset is not signed")
+			continue
+		}
+		for _, sig := range sigs {
+			key := z.signingKey(sig)
+			switch {
+			case key == nil:
+				add(k.name, k.rrtype, "dnssec-unknown-key", SeverityMedium,
+					fmt.Sprintf("signature by key %d has no matching DNSKEY", sig.KeyTag))
+				continue
+			case sig.Verify(key, rrset) != nil:
+				add(k.name, k.rrtype, "dnssec-invalid-signature", SeverityHigh,
+					fmt.Sprintf("signature by key %d does not verify", sig.KeyTag))
+				continue
+			}
+			switch {
+			case sig.ValidityPeriod(now):
+				if !sig.ValidityPeriod(now.Add(signatureExpiryWarning)) {
+					add(k.name, k.rrtype, "dnssec-expiring-signature", SeverityMedium,
+						fmt.Sprintf("signature by key %d expires %s", sig.KeyTag, dns.TimeToString(sig.Expiration)))
+				}
+			case int32(sig.Expiration-uint32(now.Unix())) < 0:
+				add(k.name, k.rrtype, "dnssec-expired-signature", SeverityHigh,
+					fmt.Sprintf("signature by key %d expired %s", sig.KeyTag, dns.TimeToString(sig.Expiration)))
+			default:
+				add(k.name, k.rrtype, "dnssec-signature-not-yet-valid", SeverityMedium,
+					fmt.Sprintf("signature by key %d is valid from %s", sig.KeyTag, dns.TimeToString(sig.Inception)))
+			}
+		}
+	}
+
+	switch {
+	case len(z.nsec) > 0:
+		for _, f := range z.checkNSEC() {
+			add(f.name, f.rrtype, f.check, f.severity, f.detail)
+		}
+	case len(z.nsec3) > 0:
+		for _, f := range z.checkNSEC3() {
+			add(f.name, f.rrtype, f.check, f.severity, f.detail)
+		}
+	default:
+		add(z.origin, dns.TypeNSEC, "dnssec-missing-denial", SeverityHigh, "signed zone has no NSEC or NSEC3 records")
+	}
+	return findings
+}
+
+// chainFinding is a problem found in an NSEC or NSEC3 chain.
+type chainFinding struct {
+	name     string
+	rrtype   uint16
+	check    string
+	severity string
+	detail   string
+}
+
+func newSignedZone(zone *models.ZoneFile) *signedZone {
+	z := &signedZone{
+		origin: dns.CanonicalName(zone.Origin),
+		rrsets: make(map[rrsetKey][]dns.RR),
+		sigs:   make(map[rrsetKey][]*dns.RRSIG),
+		nsec:   make(map[string]*dns.NSEC),
+		nsec3:  make(map[string]*dns.NSEC3),
+	}
+	for _, rec := range zone.Records {
+		rr, err := zone_files.RecordToRR(rec)
+		if err != nil {
+			continue
+		}
+		name := dns.CanonicalName(rr.Header().Name)
+		switch v := rr.(type) {
+		case *dns.RRSIG:
+			k := rrsetKey{name, v.TypeCovered}
+			z.sigs[k] = append(z.sigs[k], v)
+			continue
+		case *dns.DNSKEY:
+			if name == z.origin {
+				z.keys = append(z.keys, v)
+			}
+		case *dns.NSEC:
+			z.nsec[name] = v
+		case *dns.NSEC3:
+			z.nsec3[strings.ToUpper(dns.SplitDomainName(name)[0])] = v
+		case *dns.NSEC3PARAM:
+			z.nsec3param = v
+		case *dns.NS:
+			if name != z.origin && !slices.Contains(z.delegations, name) {
+				z.delegations = append(z.delegations, name)
+			}
+		}
+		k := rrsetKey{name, rr.Header().Rrtype}
+		z.rrsets[k] = append(z.rrsets[k], rr)
+	}
+	return z
+}
+
+func (z *signedZone) sortedKeys() []rrsetKey {
+	keys := make([]rrsetKey, 0, len(z.rrsets))
+	for k := range z.rrsets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return zone_files.CanonicalLess(keys[i].name, keys[j].name)
+		}
+		return keys[i].rrtype < keys[j].rrtype
+	})
+	return keys
+}
+
+// glue reports whether a name is below a delegation, where only glue addresses belong.
+func (z *signedZone) glue(name string) bool {
+	for _, d := range z.delegations {
+		if strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// authoritative reports whether an RRset must be signed. Only the DS and NSEC RRsets of a delegation
+// are signed by the parent and glue is not signed at all.
+func (z *signedZone) authoritative(k rrsetKey) bool {
+	if z.glue(k.name) {
+		return false
+	}
+	if slices.Contains(z.delegations, k.name) {
+		return k.rrtype == dns.TypeDS || k.rrtype == dns.TypeNSEC
+	}
+	return true
+}
+
+func (z *signedZone) signingKey(sig *dns.RRSIG) *dns.DNSKEY {
+	for _, key := range z.keys {
+		if key.KeyTag() == sig.KeyTag && key.Algorithm == sig.Algorithm && strings.EqualFold(key.Hdr.Name, sig.SignerName) {
+			return key
+		}
+	}
+	return nil
+}
+
+// ownerNames returns the authoritative names with data, excluding glue and the hashed NSEC3 owners,
+// in canonical order.
+func (z *signedZone) ownerNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for k := range z.rrsets {
+		if k.rrtype == dns.TypeNSEC3 || z.glue(k.name) || seen[k.name] {
+			continue
+		}
+		seen[k.name] = true
+		names = append(names, k.name)
+	}
+	sort.Slice(names, func(i, j int) bool { return zone_files.CanonicalLess(names[i], names[j]) })
+	return names
+}
+
+// types returns the types an NSEC or NSEC3 type bitmap should list for a name. Every signed name lists
+// RRSIG, as do NSEC records which are always signed, while insecure delegations in an NSEC3 chain do not.
+func (z *signedZone) types(name string, denial uint16) []uint16 {
+	var types []uint16
+	for k := range z.rrsets {
+		if k.name == name && k.rrtype != dns.TypeNSEC3 {
+			types = append(types, k.rrtype)
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	insecure := slices.Contains(z.delegations, name) && !slices.Contains(types, dns.TypeDS)
+	if denial == dns.TypeNSEC && !slices.Contains(types, dns.TypeNSEC) {
+		types = append(types, dns.TypeNSEC)
+	}
+	if denial == dns.TypeNSEC || !insecure {
+		types = append(types, dns.TypeRRSIG)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// checkNSEC checks every authoritative name has an NSEC record pointing to the next name in canonical
+// order, the last pointing back to the apex, with a type bitmap matching the data at the name.
+func (z *signedZone) checkNSEC() []chainFinding {
+	var findings []chainFinding
+	names := z.ownerNames()
+	for i, name := range names {
+		nsec, ok := z.nsec[name]
+		if !ok {
+			findings = append(findings, chainFinding{name, dns.TypeNSEC, "dnssec-nsec-missing", SeverityHigh,
+				"name has no NSEC record"})
+			continue
+		}
+		next := names[(i+1)%len(names)]
+		if dns.CanonicalName(nsec.NextDomain) != next {
+			findings = append(findings, chainFinding{name, dns.TypeNSEC, "dnssec-nsec-chain-broken", SeverityHigh,
+				fmt.Sprintf("NSEC points to %s rather than %s", nsec.NextDomain, next)})
+		}
+		if !sameTypes(nsec.TypeBitMap, z.types(name, dns.TypeNSEC)) {
+			findings = append(findings, chainFinding{name, dns.TypeNSEC, "dnssec-nsec-type-mismatch", SeverityMedium,
+				"NSEC type bitmap does not match the records at the name"})
+		}
+	}
+	return findings
+}
+
+// checkNSEC3 checks every authoritative name and empty non-terminal has an NSEC3 record for its hash
+// and the hashes form a closed chain. Insecure delegations may be left out of opt-out chains.
+func (z *signedZone) checkNSEC3() []chainFinding {
+	var findings []chainFinding
+	var params *dns.NSEC3
+	hashes := make([]string, 0, len(z.nsec3))
+	for hash, rr := range z.nsec3 {
+		hashes = append(hashes, hash)
+		if params == nil {
+			params = rr
+		}
+	}
+	sort.Strings(hashes)
+	hashAlg, iterations, salt := params.Hash, params.Iterations, params.Salt
+	if p := z.nsec3param; p != nil {
+		hashAlg, iterations, salt = p.Hash, p.Iterations, p.Salt
+	}
+	if iterations > 0 {
+		findings = append(findings, chainFinding{z.origin, dns.TypeNSEC3PARAM, "dnssec-nsec3-iterations", SeverityLow,
+			fmt.Sprintf("NSEC3 uses %d additional iterations where RFC 9276 recommends none", iterations)})
+	}
+
+	for _, name := range z.nsec3Names() {
+		optOut := slices.Contains(z.delegations, name) && len(z.rrsets[rrsetKey{name, dns.TypeDS}]) == 0
+		rr, ok := z.nsec3[dns.HashName(name, hashAlg, iterations, salt)]
+		switch {
+		case !ok && !(optOut && params.Flags&1 == 1):
+			findings = append(findings, chainFinding{name, dns.TypeNSEC3, "dnssec-nsec3-missing", SeverityHigh,
+				"name has no NSEC3 record"})
+		case ok && !sameTypes(rr.TypeBitMap, z.types(name, dns.TypeNSEC3)):
+			findings = append(findings, chainFinding{name, dns.TypeNSEC3, "dnssec-nsec3-type-mismatch", SeverityMedium,
+				"NSEC3 type bitmap does not match the records at the name"})
+		}
+	}
+
+	for i, hash := range hashes {
+		next := hashes[(i+1)%len(hashes)]
+		if strings.ToUpper(z.nsec3[hash].NextDomain) != next {
+			findings = append(findings, chainFinding{dns.CanonicalName(z.nsec3[hash].Hdr.Name), dns.TypeNSEC3,
+				"dnssec-nsec3-chain-broken", SeverityHigh,
+				fmt.Sprintf("NSEC3 points to %s rather than %s", z.nsec3[hash].NextDomain, next)})
+		}
+	}
+	return findings
+}
+
+// nsec3Names returns the authoritative names along with the empty non-terminals between them and the apex.
+func (z *signedZone) nsec3Names() []string {
+	names := z.ownerNames()
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, name := range z.ownerNames() {
+		for parent := name; parent != z.origin && dns.IsSubDomain(z.origin, parent); {
+			labels := dns.SplitDomainName(parent)
+			parent = dns.Fqdn(strings.Join(labels[1:], "."))
+			if !seen[parent] {
+				seen[parent] = true
+				names = append(names, parent)
+			}
+		}
+	}
+	return names
+}
+
+func sameTypes(bitmap, expected []uint16) bool {
+	if len(bitmap) != len(expected) {
+		return false
+	}
+	sorted := append([]uint16(nil), bitmap...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i := range sorted {
+		if sorted[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rsaKeyBits returns the modulus size of an RSA DNSKEY, or zero for other algorithms.
+func rsaKeyBits(key *dns.DNSKEY) int {
+	switch key.Algorithm {
+	case dns.RSAMD5, dns.RSASHA1, dns.RSASHA1NSEC3SHA1, dns.RSASHA256, dns.RSASHA512:
+	default:
+		return 0
+	}
+	raw, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil || len(raw) < 3 {
+		return 0
+	}
+	// RFC 3110: a one byte exponent length, or zero followed by a two byte length, then the exponent and modulus.
+	expLen, offset := int(raw[0]), 1
+	if expLen == 0 {
+		expLen, offset = int(raw[1])<<8|int(raw[2]), 3
+	}
+	if offset+expLen >= len(raw) {
+		return 0
+	}
+	return new(big.Int).SetBytes(raw[offset+expLen:]).BitLen()
+}
@@ -0,0 +1,200 @@
+package zone_lint
+
+import (
+	"crypto"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"orbit/models"
+	"orbit/pkg/zone_files"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var unsignedZone = []string{
+	"example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 900 1209600 300",
+	"example.com. 3600 IN NS ns1.example.com.",
+	"ns1.example.com. 3600 IN A 192.0.2.53",
+	"www.example.com. 3600 IN A 192.0.2.10",
+	"a.b.example.com. 3600 IN A 192.0.2.11",
+	"sub.example.com. 3600 IN NS ns.sub.example.com.",
+	"ns.sub.example.com. 3600 IN A 192.0.2.54",
+}
+
+// signZone signs the fixture zone with a new key and adds an NSEC or NSEC3 chain. The edit function
+// may change the records before they are returned.
+func signZone(t *testing.T, alg uint8, bits int, nsec3 bool, expiration time.Time, edit func([]dns.RR) []dns.RR) *models.ZoneFile {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: alg,
+	}
+	priv, err := key.Generate(bits)
+	assert.NoError(t, err)
+
+	var rrs []dns.RR
+	for _, s := range unsignedZone {
+		rr, err := dns.NewRR(s)
+		assert.NoError(t, err)
+		rrs = append(rrs, rr)
+	}
+	rrs = append(rrs, key)
+
+	// Types at each name which are covered by the denial of existence chain.
+	types := map[string][]uint16{}
+	for _, rr := range rrs {
+		name := rr.Header().Name
+		if name != "ns.sub.example.com." {
+			types[name] = append(types[name], rr.Header().Rrtype)
+		}
+	}
+	var names []string
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return zone_files.CanonicalLess(names[i], names[j]) })
+
+	if nsec3 {
+		rrs = append(rrs, &dns.NSEC3PARAM{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeNSEC3PARAM,
+			Class: dns.ClassINET, Ttl: 0}, Hash: dns.SHA1})
+		types["example.com."] = append(types["example.com."], dns.TypeNSEC3PARAM)
+		// The empty non-terminal b.example.com has an NSEC3 record with an empty bitmap.
+		hashed := map[string]string{dns.HashName("b.example.com.", dns.SHA1, 0, ""): "b.example.com."}
+		for _, name := range names {
+			hashed[dns.HashName(name, dns.SHA1, 0, "")] = name
+		}
+		var hashes []string
+		for h := range hashed {
+			hashes = append(hashes, h)
+		}
+		sort.Strings(hashes)
+		for i, h := range hashes {
+			bitmap := types[hashed[h]]
+			if len(bitmap) > 0 && hashed[h] != "sub.example.com." {
+				bitmap = append(bitmap, dns.TypeRRSIG)
+			}
+			rrs = append(rrs, &dns.NSEC3{Hdr: dns.RR_Header{Name: strings.ToLower(h) + ".example.com.", Rrtype: dns.TypeNSEC3,
+				Class: dns.ClassINET, Ttl: 300}, Hash: dns.SHA1, HashLength: 20, NextDomain: hashes[(i+1)%len(hashes)], TypeBitMap: sorted(bitmap)})
+		}
+	} else {
+		for i, name := range names {
+			bitmap := append(types[name], dns.TypeRRSIG, dns.TypeNSEC)
+			rrs = append(rrs, &dns.NSEC{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+				NextDomain: names[(i+1)%len(names)], TypeBitMap: sorted(bitmap)})
+		}
+	}
+
+	sets := map[rrsetKey][]dns.RR{}
+	var order []rrsetKey
+	for _, rr := range rrs {
+		k := rrsetKey{rr.Header().Name, rr.Header().Rrtype}
+		if k.name == "ns.sub.example.com." || (k.name == "sub.example.com." && k.rrtype == dns.TypeNS) {
+			continue
+		}
+		if _, ok := sets[k]; !ok {
+			order = append(order, k)
+		}
+		sets[k] = append(sets[k], rr)
+	}
+	for _, k := range order {
+		sig := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Name: k.name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: sets[k][0].Header().Ttl},
+			Algorithm:  alg,
+			KeyTag:     key.KeyTag(),
+			SignerName: "example.com.",
+			Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+			Expiration: uint32(expiration.Unix()),
+		}
+		assert.NoError(t, sig.Sign(priv.(crypto.Signer), sets[k]))
+		rrs = append(rrs, sig)
+	}
+	if edit != nil {
+		rrs = edit(rrs)
+	}
+
+	zone := &models.ZoneFile{Origin: "example.com"}
+	for _, rr := range rrs {
+		zone.Records = append(zone.Records, zone_files.RecordFromRR(rr))
+	}
+	return zone
+}
+
+func sorted(types []uint16) []uint16 {
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func checks(findings []models.ZoneFinding) []string {
+	var results []string
+	for _, f := range findings {
+		results = append(results, f.Check+" "+f.Name)
+	}
+	return results
+}
+
+func TestValidateDNSSEC(t *testing.T) {
+	zl := ZoneLinter{}
+	now := time.Now()
+	month := now.Add(30 * 24 * time.Hour)
+
+	t.Run("A correctly signed zone has no findings.", func(t *testing.T) {
+		assert.Empty(t, checks(zl.ValidateDNSSEC(signZone(t, dns.ECDSAP256SHA256, 256, false, month, nil), now)))
+		assert.Empty(t, checks(zl.ValidateDNSSEC(signZone(t, dns.ECDSAP256SHA256, 256, true, month, nil), now)))
+	})
+
+	t.Run("Unsigned zones are not checked.", func(t *testing.T) {
+		zone := &models.ZoneFile{Origin: "example.com", Records: []models.DNSRecord{{Type: "A", Class: "IN",
+			Name: "www.example.com", Content: "192.0.2.1", TTL: 60}}}
+		assert.Nil(t, zl.ValidateDNSSEC(zone, now))
+	})
+
+	t.Run("Modified records no longer verify.", func(t *testing.T) {
+		zone := signZone(t, dns.ECDSAP256SHA256, 256, false, month, func(rrs []dns.RR) []dns.RR {
+			for _, rr := range rrs {
+				if a, ok := rr.(*dns.A); ok && a.Hdr.Name == "www.example.com." {
+					a.A = a.A.To4()
+					a.A[3] = 99
+				}
+			}
+			return rrs
+		})
+		assert.Equal(t, []string{"dnssec-invalid-signature www.example.com"}, checks(zl.ValidateDNSSEC(zone, now)))
+	})
+
+	t.Run("Expired and soon to expire signatures are reported.", func(t *testing.T) {
+		zone := signZone(t, dns.ECDSAP256SHA256, 256, false, now.Add(72*time.Hour), nil)
+		findings := zl.ValidateDNSSEC(zone, now)
+		assert.NotEmpty(t, findings)
+		assert.Equal(t, "dnssec-expiring-signature", findings[0].Check)
+
+		findings = zl.ValidateDNSSEC(zone, now.Add(96*time.Hour))
+		assert.Equal(t, "dnssec-expired-signature", findings[0].Check)
+	})
+
+	t.Run("Gaps in the NSEC and NSEC3 chains are reported.", func(t *testing.T) {
+		dropFirst := func(rrtype uint16) func([]dns.RR) []dns.RR {
+			return func(rrs []dns.RR) []dns.RR {
+				for i, rr := range rrs {
+					if rr.Header().Rrtype == rrtype && rr.Header().Name != "example.com." {
+						return append(rrs[:i], rrs[i+1:]...)
+					}
+				}
+				return rrs
+			}
+		}
+		nsec := checks(zl.ValidateDNSSEC(signZone(t, dns.ECDSAP256SHA256, 256, false, month, dropFirst(dns.TypeNSEC)), now))
+		assert.Equal(t, []string{"dnssec-nsec-missing a.b.example.com"}, nsec)
+
+		nsec3 := checks(zl.ValidateDNSSEC(signZone(t, dns.ECDSAP256SHA256, 256, true, month, dropFirst(dns.TypeNSEC3)), now))
+		assert.Len(t, nsec3, 2)
+		assert.Contains(t, strings.Join(nsec3, ","), "dnssec-nsec3-missing")
+		assert.Contains(t, strings.Join(nsec3, ","), "dnssec-nsec3-chain-broken")
+	})
+
+	t.Run("RSA/SHA-1 and short RSA keys are weak.", func(t *testing.T) {
+		findings := checks(zl.ValidateDNSSEC(signZone(t, dns.RSASHA1, 1024, false, month, nil), now))
+		assert.Equal(t, []string{"dnssec-weak-algorithm example.com", "dnssec-short-key example.com"}, findings)
+	})
+}
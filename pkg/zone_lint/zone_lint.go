@@ -0,0 +1,229 @@
+package zone_lint
+
+import (
+	"fmt"
+	"orbit/models"
+	"orbit/pkg/reporting"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Finding severities.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+	SeverityInfo   = "info"
+)
+
+// TTLs outside of this range are reported as unreasonable.
+const (
+	minTTL = 30
+	maxTTL = 604800
+)
+
+// SOA timer ranges recommended by RFC 1912 section 2.2 and RFC 2308 section 5.
+const (
+	minRefresh     = 1200
+	maxRefresh     = 43200
+	minExpire      = 1209600
+	maxExpire      = 2419200
+	maxNegativeTTL = 86400
+)
+
+// disclosureTypes are record types which reveal details about hosts or the people running them.
+var disclosureTypes = map[string]string{
+	"HINFO": "host hardware and operating system",
+	"RP":    "responsible person contact",
+	"LOC":   "geographical location",
+}
+
+// dnssecTypes may coexist with a CNAME at the same name.
+var dnssecTypes = []string{"RRSIG", "NSEC", "NSEC3"}
+
+type ZoneLinter struct{}
+
+// zoneIndex holds the record types present at each name of a zone.
+type zoneIndex struct {
+	origin      string
+	types       map[string][]string
+	wildcards   []string
+	delegations []string
+}
+
+// LintZone checks a zone for configuration errors and records which disclose information.
+func (zl *ZoneLinter) LintZone(zone *models.ZoneFile) []models.ZoneFinding {
+	idx := newZoneIndex(zone)
+	var findings []models.ZoneFinding
+	add := func(rec models.DNSRecord, check, severity, detail string) {
+		findings = append(findings, models.ZoneFinding{
+			Zone:     zone.Origin,
+			Name:     rec.Name,
+			Type:     rec.Type,
+			Check:    check,
+			Severity: severity,
+			Detail:   detail,
+		})
+	}
+
+	seen := make(map[string]bool)
+	reported := make(map[string]bool)
+	soaCount := 0
+	for _, rec := range zone.Records {
+		name := strings.ToLower(rec.Name)
+
+		key := name + " " + rec.Class + " " + rec.Type + " " + rec.Content
+		if seen[key] && !reported[key] {
+			add(rec, "duplicate-record", SeverityLow, "record is defined more than once: "+rec.Content)
+			reported[key] = true
+		}
+		seen[key] = true
+
+		if hasTTL(rec) && (rec.TTL < minTTL || rec.TTL > maxTTL) {
+			add(rec, "unreasonable-ttl", SeverityLow, fmt.Sprintf("TTL of %d seconds is outside %d-%d", rec.TTL, minTTL, maxTTL))
+		}
+		if detail, ok := disclosureTypes[rec.Type]; ok {
+			add(rec, "information-disclosure", SeverityInfo, detail+": "+rec.Content)
+		}
+
+		switch rec.Type {
+		case "CNAME":
+			if name == idx.origin {
+				add(rec, "cname-at-apex", SeverityHigh, "CNAME at the zone apex conflicts with the SOA and NS records")
+			} else if others := idx.conflicting(name); len(others) > 0 && !reported[name+" CNAME"] {
+				add(rec, "cname-with-other-data", SeverityHigh, "CNAME shares its name with "+strings.Join(others, ", "))
+				reported[name+" CNAME"] = true
+			}
+		case "NS":
+			if idx.contains(rec.Target) && !idx.hasAddress(rec.Target) {
+				add(rec, "missing-glue", SeverityMedium, "in-bailiwick nameserver "+rec.Target+" has no A or AAAA record")
+			}
+		case "SOA":
+			soaCount++
+			if soaCount == 2 {
+				add(rec, "multiple-soa", SeverityHigh, "zone has more than one SOA record")
+			}
+			for _, problem := range soaProblems(rec.SOA) {
+				add(rec, "soa-timers", SeverityLow, problem)
+			}
+		}
+
+		if rec.Type != "NS" && rec.Type != "SOA" {
+			for _, target := range idx.danglingTargets(rec) {
+				add(rec, "dangling-target", SeverityMedium, "target "+target+" does not exist in the zone")
+			}
+		}
+	}
+	return findings
+}
+
+func newZoneIndex(zone *models.ZoneFile) *zoneIndex {
+	idx := &zoneIndex{origin: strings.ToLower(zone.Origin), types: make(map[string][]string)}
+	for _, rec := range zone.Records {
+		name := strings.ToLower(rec.Name)
+		if !slices.Contains(idx.types[name], rec.Type) {
+			idx.types[name] = append(idx.types[name], rec.Type)
+		}
+		if strings.HasPrefix(name, "*.") {
+			idx.wildcards = append(idx.wildcards, name[1:])
+		}
+		if rec.Type == "NS" && name != idx.origin && !slices.Contains(idx.delegations, name) {
+			idx.delegations = append(idx.delegations, name)
+		}
+	}
+	for name := range idx.types {
+		sort.Strings(idx.types[name])
+	}
+	return idx
+}
+
+// contains reports whether a name is at or below the zone origin.
+func (idx *zoneIndex) contains(name string) bool {
+	name = strings.ToLower(name)
+	return name == idx.origin || strings.HasSuffix(name, "."+idx.origin)
+}
+
+// delegated reports whether a name is at or below a delegation to another zone.
+func (idx *zoneIndex) delegated(name string) bool {
+	for _, d := range idx.delegations {
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// exists reports whether a name has records in the zone, directly or through a wildcard.
+func (idx *zoneIndex) exists(name string) bool {
+	if len(idx.types[name]) > 0 {
+		return true
+	}
+	for _, w := range idx.wildcards {
+		if strings.HasSuffix(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *zoneIndex) hasAddress(name string) bool {
+	types := idx.types[strings.ToLower(name)]
+	return slices.Contains(types, "A") || slices.Contains(types, "AAAA")
+}
+
+// conflicting returns the record types which may not share a name with a CNAME.
+func (idx *zoneIndex) conflicting(name string) []string {
+	var results []string
+	for _, t := range idx.types[name] {
+		if t != "CNAME" && !slices.Contains(dnssecTypes, t) {
+			results = append(results, t)
+		}
+	}
+	return results
+}
+
+// danglingTargets returns the in-zone names a record points to which have no records. Targets below
+// a delegation belong to another zone and are not checked.
+func (idx *zoneIndex) danglingTargets(rec models.DNSRecord) []string {
+	rep := reporting.Reporting{}
+	var results []string
+	for _, target := range rep.RecordTargets(rec) {
+		target = strings.ToLower(target)
+		if target == "." || !idx.contains(target) || idx.delegated(target) || idx.exists(target) {
+			continue
+		}
+		results = append(results, target)
+	}
+	return results
+}
+
+// soaProblems checks SOA timers against each other and the ranges recommended for them.
+func soaProblems(soa *models.SOAData) []string {
+	if soa == nil {
+		return nil
+	}
+	var problems []string
+	if soa.Refresh < minRefresh || soa.Refresh > maxRefresh {
+		problems = append(problems, fmt.Sprintf("refresh of %d seconds is outside %d-%d", soa.Refresh, minRefresh, maxRefresh))
+	}
+	if soa.Retry >= soa.Refresh {
+		problems = append(problems, fmt.Sprintf("retry of %d seconds is not less than refresh of %d", soa.Retry, soa.Refresh))
+	}
+	if soa.Expire < minExpire || soa.Expire > maxExpire {
+		problems = append(problems, fmt.Sprintf("expire of %d seconds is outside %d-%d", soa.Expire, minExpire, maxExpire))
+	}
+	if soa.Expire <= soa.Refresh+soa.Retry {
+		problems = append(problems, fmt.Sprintf("expire of %d seconds does not exceed refresh plus retry", soa.Expire))
+	}
+	if soa.Minimum > maxNegativeTTL {
+		problems = append(problems, fmt.Sprintf("negative caching TTL of %d seconds exceeds %d", soa.Minimum, maxNegativeTTL))
+	}
+	return problems
+}
+
+// hasTTL reports whether a record carries a TTL of its own. Provider aliases are answered with the TTL
+// of their target and imported provider records without one, i.e., Route 53 aliases, are left at zero.
+func hasTTL(rec models.DNSRecord) bool {
+	return rec.Type != "ALIAS" && (rec.TTL != 0 || len(rec.Attributes) == 0)
+}
@@ -0,0 +1,111 @@
+package zone_lint
+
+import (
+	"github.com/stretchr/testify/assert"
+	"orbit/models"
+	"orbit/pkg/zone_files"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var lintZone = `$ORIGIN example.com.
+$TTL 3600
+@	SOA	ns1 hostmaster 1 300 600 86400 172800
+@	NS	ns1
+@	NS	ns2
+@	CNAME	other.example.net.
+ns1	A	192.0.2.53
+www	A	192.0.2.10
+www	A	192.0.2.10
+www	TXT	"v=spf1 -all"
+app	CNAME	www
+app	A	192.0.2.11
+old	CNAME	gone
+mail	MX	10 mx
+fast	5	A	192.0.2.12
+server	HINFO	"x86" "Linux"
+*.dev	A	192.0.2.13
+api	CNAME	foo.dev
+sub	NS	ns.sub
+legacy	CNAME	host.sub
+`
+
+func lint(t *testing.T) []models.ZoneFinding {
+	path := filepath.Join(t.TempDir(), "example.com.zone")
+	assert.NoError(t, os.WriteFile(path, []byte(lintZone), 0o644))
+	dz := zone_files.DNSZones{}
+	zones, err := dz.GetZoneData(path)
+	assert.NoError(t, err)
+	zl := ZoneLinter{}
+	return zl.LintZone(&zones[0])
+}
+
+func findings(results []models.ZoneFinding, check string) []models.ZoneFinding {
+	var matched []models.ZoneFinding
+	for _, f := range results {
+		if f.Check == check {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
+
+func TestLintZone(t *testing.T) {
+	results := lint(t)
+
+	t.Run("CNAMEs at the apex or beside other data are reported.", func(t *testing.T) {
+		assert.Len(t, findings(results, "cname-at-apex"), 1)
+		other := findings(results, "cname-with-other-data")
+		assert.Len(t, other, 1)
+		assert.Equal(t, "app.example.com", other[0].Name)
+	})
+
+	t.Run("Duplicate records are reported once.", func(t *testing.T) {
+		dups := findings(results, "duplicate-record")
+		assert.Len(t, dups, 1)
+		assert.Equal(t, "www.example.com", dups[0].Name)
+	})
+
+	t.Run("Missing in-zone targets are reported unless covered by a wildcard or delegation.", func(t *testing.T) {
+		var names []string
+		for _, f := range findings(results, "dangling-target") {
+			names = append(names, f.Name)
+		}
+		assert.ElementsMatch(t, []string{"old.example.com", "mail.example.com"}, names)
+	})
+
+	t.Run("In-bailiwick nameservers without addresses are missing glue.", func(t *testing.T) {
+		var names []string
+		for _, f := range findings(results, "missing-glue") {
+			names = append(names, f.Detail)
+		}
+		assert.Len(t, names, 2)
+		assert.Contains(t, names[0], "ns2.example.com")
+		assert.Contains(t, names[1], "ns.sub.example.com")
+	})
+
+	t.Run("Unreasonable TTLs and SOA timers are reported.", func(t *testing.T) {
+		assert.Len(t, findings(results, "unreasonable-ttl"), 1)
+		// Refresh is too short, retry exceeds refresh, expire is too short and the negative TTL too long.
+		assert.Len(t, findings(results, "soa-timers"), 4)
+	})
+
+	t.Run("Provider aliases without a TTL of their own are not reported.", func(t *testing.T) {
+		zone := models.ZoneFile{Origin: "example.com", Records: []models.DNSRecord{
+			{Name: "example.com", Type: "ALIAS", Class: "IN", Target: "lb.example.net", Attributes: map[string]string{"alias-type": "A"}},
+			{Name: "www.example.com", Type: "A", Class: "IN", Content: "192.0.2.1", Attributes: map[string]string{"routing": "weighted"}},
+			{Name: "ftp.example.com", Type: "A", Class: "IN", Content: "192.0.2.2"},
+		}}
+		zl := ZoneLinter{}
+		ttls := findings(zl.LintZone(&zone), "unreasonable-ttl")
+		assert.Len(t, ttls, 1)
+		assert.Equal(t, "ftp.example.com", ttls[0].Name)
+	})
+
+	t.Run("HINFO, RP and LOC records disclose information.", func(t *testing.T) {
+		disclosed := findings(results, "information-disclosure")
+		assert.Len(t, disclosed, 1)
+		assert.Equal(t, "HINFO", disclosed[0].Type)
+	})
+}